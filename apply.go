@@ -0,0 +1,97 @@
+package radix
+
+import (
+	"github.com/pkg/errors"
+	"time"
+)
+
+// OpKind kind of batch operation
+type OpKind int
+
+const (
+	OpInvalid = OpKind(0)
+	// Insert or update a key
+	OpInsert = OpKind(1)
+	// Delete an existing key
+	OpDelete = OpKind(2)
+)
+
+// Op is a single operation of a batch passed to Apply
+type Op struct {
+	Kind  OpKind
+	Key   string
+	Value interface{}
+}
+
+// undoEntry records the state of a key before an operation
+type undoEntry struct {
+	key     string
+	existed bool
+	val     interface{}
+	expires time.Time
+}
+
+// Apply applies a batch of operations atomically. Operations are
+// applied in order and validated against the state left by the
+// previous ones. If any operation is invalid, either of an unknown
+// kind or deleting a missing or expired key, all applied operations
+// are rolled back and the tree is left as it was. Once the whole
+// batch succeeded it is logged to the write-ahead log as a single
+// record, replayed all or nothing, and followed by a cleanup sweep.
+func (t *Tree) Apply(ops []Op) error {
+	undo := make([]undoEntry, 0, len(ops))
+	for i, op := range ops {
+		// The undo entry restores the leaf as stored, even if
+		// expired, but ops are validated against what reads see
+		u := undoEntry{key: op.Key}
+		visible := false
+		isFound, _, _, n := t.Find(t.root, op.Key)
+		if isFound && n.HasValue() {
+			u.existed = true
+			u.val = n.leaf.val
+			u.expires = n.leaf.expiresAt()
+			visible = !t.expired(n.leaf)
+		}
+
+		var err error
+		switch op.Kind {
+		case OpInsert:
+			t.insert(op.Key, op.Value)
+		case OpDelete:
+			if !visible {
+				err = errors.Errorf("key %q not found", op.Key)
+				break
+			}
+			t.deleteIf(op.Key, nil)
+		default:
+			err = errors.Errorf("invalid op kind %d", op.Kind)
+		}
+		if err != nil {
+			t.rollback(undo)
+			return errors.Wrapf(err, "can't apply op %d", i)
+		}
+
+		undo = append(undo, u)
+	}
+
+	t.logBatch(ops)
+	t.maybeSweep()
+	return nil
+}
+
+// rollback reverts the operations recorded in the undo log
+func (t *Tree) rollback(undo []undoEntry) {
+	for i := len(undo) - 1; i >= 0; i-- {
+		u := undo[i]
+		if !u.existed {
+			t.deleteIf(u.key, nil)
+			continue
+		}
+
+		t.insert(u.key, u.val)
+		if !u.expires.IsZero() {
+			_, _, _, n := t.Find(t.root, u.key)
+			t.setExpiry(u.key, n.leaf, u.expires)
+		}
+	}
+}
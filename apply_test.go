@@ -0,0 +1,119 @@
+package radix
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	r := New()
+	r.Insert("foo", 1)
+	r.Insert("bar", 2)
+
+	err := r.Apply([]Op{
+		{Kind: OpInsert, Key: "foo", Value: 10},
+		{Kind: OpInsert, Key: "zip", Value: 3},
+		{Kind: OpDelete, Key: "bar"},
+	})
+	if err != nil {
+		t.Fatalf("can't apply: %s", err)
+	}
+
+	expected := map[string]interface{}{"foo": 10, "zip": 3}
+	if out := r.ToMap(); !reflect.DeepEqual(out, expected) {
+		t.Fatalf("mis-match: %v %v", out, expected)
+	}
+}
+
+func TestApplyRollback(t *testing.T) {
+	cases := [][]Op{
+		{
+			{Kind: OpInsert, Key: "foo", Value: 10},
+			{Kind: OpInsert, Key: "foobar", Value: 20},
+			{Kind: OpDelete, Key: "bar"},
+			{Kind: OpDelete, Key: "missing"},
+		},
+		{
+			{Kind: OpDelete, Key: "foo"},
+			{Kind: OpInsert, Key: "foo", Value: 10},
+			{Kind: OpInvalid, Key: "zip"},
+		},
+		{
+			{Kind: OpDelete, Key: "bar"},
+			{Kind: OpDelete, Key: "bar"},
+		},
+	}
+
+	for _, ops := range cases {
+		r := New()
+		r.Insert("foo", 1)
+		r.Insert("bar", 2)
+		before := r.ToMap()
+
+		if err := r.Apply(ops); err == nil {
+			t.Fatalf("expected error: %v", ops)
+		}
+		if out := r.ToMap(); !reflect.DeepEqual(out, before) {
+			t.Fatalf("mis-match: %v %v", out, before)
+		}
+		if r.Len() != len(before) {
+			t.Fatalf("bad len: %v", r.Len())
+		}
+	}
+}
+
+func TestApplyRollbackTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var log bytes.Buffer
+	r := NewWithOptions(
+		WithClock(clock),
+		WithCleanup(CleanupPolicy{Interval: time.Second}),
+		WithWAL(&log),
+	)
+	r.InsertWithTTL("foo", 1, time.Second)
+	r.InsertWithTTL("bar", 2, time.Hour)
+	logged := log.Len()
+
+	clock.Advance(time.Minute)
+	err := r.Apply([]Op{
+		{Kind: OpInsert, Key: "bar", Value: 20},
+		{Kind: OpDelete, Key: "missing"},
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// The expired entry is not swept and nothing is logged
+	if r.Len() != 2 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+	if log.Len() != logged {
+		t.Fatalf("rolled back batch was logged")
+	}
+
+	// Expired keys can't be deleted, as they are hidden from reads
+	err = r.Apply([]Op{
+		{Kind: OpInsert, Key: "zip", Value: 3},
+		{Kind: OpDelete, Key: "foo"},
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if r.Len() != 2 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+	if _, ok := r.Lookup("zip"); ok {
+		t.Fatalf("rolled back insert is present")
+	}
+
+	// The TTL of the updated key is restored
+	if v, ok := r.Lookup("bar"); !ok || v != 2 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	clock.Advance(time.Hour)
+	if _, ok := r.Lookup("bar"); ok {
+		t.Fatalf("expected expired")
+	}
+}
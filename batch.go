@@ -0,0 +1,153 @@
+package radix
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Match is the result of a single lookup of LongestPrefixBatch
+type Match struct {
+	// Key is the looked up key
+	Key string
+	// Prefix is the longest stored key that is a prefix of Key
+	Prefix string
+	// Value is the value stored under Prefix
+	Value interface{}
+	// Found is set if a stored key is a prefix of Key
+	Found bool
+}
+
+// BatchOptions tunes LongestPrefixBatchWith
+type BatchOptions struct {
+	// Workers is the number of goroutines sharing the lookups,
+	// one if zero. The tree must not be modified until the batch
+	// returns, and a custom Clock must be safe for concurrent use.
+	Workers int
+	// Sorted makes the lookups run in key order, each one
+	// resuming from the nodes the previous key went through
+	// that are shared with it instead of descending from the
+	// root. Keys already in order are not sorted again, so this
+	// pays off most for batches which are sorted by the caller.
+	Sorted bool
+}
+
+// LongestPrefixBatch is like LongestPrefixOK for many keys at
+// once. Results are returned in the order of keys.
+func (t *Tree) LongestPrefixBatch(keys []string) []Match {
+	return t.LongestPrefixBatchWith(keys, BatchOptions{})
+}
+
+// LongestPrefixBatchWith is like LongestPrefixBatch, with options
+func (t *Tree) LongestPrefixBatchWith(keys []string, opts BatchOptions) []Match {
+	out := make([]Match, len(keys))
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	if opts.Sorted && !sort.StringsAreSorted(keys) {
+		sort.Slice(order, func(i, j int) bool {
+			return keys[order[i]] < keys[order[j]]
+		})
+	}
+	lookup := t.longestPrefixBatch
+	if opts.Sorted {
+		lookup = t.longestPrefixBatchShared
+	}
+
+	workers := opts.Workers
+	if workers > len(order) {
+		workers = len(order)
+	}
+	if workers <= 1 {
+		lookup(keys, order, out)
+		return out
+	}
+
+	var wg sync.WaitGroup
+	chunk := (len(order) + workers - 1) / workers
+	for start := 0; start < len(order); start += chunk {
+		end := start + chunk
+		if end > len(order) {
+			end = len(order)
+		}
+		wg.Add(1)
+		go func(order []int) {
+			defer wg.Done()
+			lookup(keys, order, out)
+		}(order[start:end])
+	}
+	wg.Wait()
+	return out
+}
+
+// batchStep is a node reached while looking up a key of a
+// batch, along with how much of the key was consumed to reach
+// it and the longest match found on the way
+type batchStep struct {
+	n        *Node
+	consumed int
+	last     *Node
+	lastLen  int
+}
+
+// longestPrefixBatch resolves the keys at the given
+// indexes, storing the results at the same indexes in out
+func (t *Tree) longestPrefixBatch(keys []string, order []int, out []Match) {
+	for _, i := range order {
+		s := keys[i]
+		m := Match{Key: s}
+		if keyLen, n := t.longestMatch(t.root, s); n != nil {
+			m.Prefix = s[:keyLen]
+			m.Value = n.leaf.val
+			m.Found = true
+		}
+		out[i] = m
+	}
+}
+
+// longestPrefixBatchShared is like longestPrefixBatch, but keeps
+// the path of the previous key and reuses it for the prefix it
+// shares with the next one
+func (t *Tree) longestPrefixBatchShared(keys []string, order []int, out []Match) {
+	root := batchStep{n: t.root}
+	if t.root.HasValue() && !t.expired(t.root.leaf) {
+		root.last = t.root
+	}
+	path := []batchStep{root}
+
+	prev := ""
+	for _, i := range order {
+		s := keys[i]
+
+		// Drop the steps which consumed more than the shared prefix
+		shared := longestPrefix(prev, s)
+		for path[len(path)-1].consumed > shared {
+			path = path[:len(path)-1]
+		}
+
+		step := path[len(path)-1]
+		for step.consumed < len(s) {
+			child := step.n.getEdge(s[step.consumed])
+			if child == nil || !strings.HasPrefix(s[step.consumed:], child.prefix) {
+				break
+			}
+			step.n = child
+			step.consumed += len(child.prefix)
+			if child.HasValue() && !t.expired(child.leaf) {
+				step.last = child
+				step.lastLen = step.consumed
+			}
+			path = append(path, step)
+		}
+
+		m := Match{Key: s}
+		if step.last != nil {
+			m.Prefix = s[:step.lastLen]
+			m.Value = step.last.leaf.val
+			m.Found = true
+		}
+		out[i] = m
+		prev = s
+	}
+}
@@ -0,0 +1,135 @@
+package radix
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLongestPrefixBatch(t *testing.T) {
+	r := New()
+	r.Insert("foo", 1)
+	r.Insert("foobar", 2)
+	r.Insert("zip", 3)
+
+	keys := []string{"zipzap", "foob", "a", "foobarbaz", "", "foo"}
+	expected := []Match{
+		{Key: "zipzap", Prefix: "zip", Value: 3, Found: true},
+		{Key: "foob", Prefix: "foo", Value: 1, Found: true},
+		{Key: "a"},
+		{Key: "foobarbaz", Prefix: "foobar", Value: 2, Found: true},
+		{Key: ""},
+		{Key: "foo", Prefix: "foo", Value: 1, Found: true},
+	}
+
+	if out := r.LongestPrefixBatch(keys); !reflect.DeepEqual(out, expected) {
+		t.Fatalf("mis-match: %v %v", out, expected)
+	}
+	for _, workers := range []int{0, 2, 4, 100} {
+		for _, sorted := range []bool{false, true} {
+			out := r.LongestPrefixBatchWith(keys, BatchOptions{Workers: workers, Sorted: sorted})
+			if !reflect.DeepEqual(out, expected) {
+				t.Fatalf("mis-match with %d workers: %v %v", workers, out, expected)
+			}
+		}
+	}
+
+	if out := r.LongestPrefixBatch(nil); len(out) != 0 {
+		t.Fatalf("bad: %v", out)
+	}
+}
+
+func TestLongestPrefixBatchRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	key := func() string {
+		b := make([]byte, rnd.Intn(8))
+		for i := range b {
+			b[i] = "ab/"[rnd.Intn(3)]
+		}
+		return string(b)
+	}
+
+	r := New()
+	for i := 0; i < 200; i++ {
+		r.Insert(key(), i)
+	}
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = key()
+	}
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+
+	for _, inp := range [][]string{keys, sorted} {
+		for _, opts := range []BatchOptions{{}, {Sorted: true}} {
+			for i, m := range r.LongestPrefixBatchWith(inp, opts) {
+				prefix, v, ok := r.LongestPrefixOK(inp[i])
+				if m.Key != inp[i] || m.Prefix != prefix || m.Value != v || m.Found != ok {
+					t.Fatalf("mis-match %q: %v", inp[i], m)
+				}
+			}
+		}
+	}
+}
+
+// batchBenchKeys builds a tree of IP-like prefixes and lookup
+// keys below them
+func batchBenchKeys() (*Tree, []string) {
+	rnd := rand.New(rand.NewSource(1))
+	r := New()
+	for i := 0; i < 10000; i++ {
+		r.Insert(fmt.Sprintf("10.%d.%d.", rnd.Intn(64), rnd.Intn(256)), i)
+	}
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("10.%d.%d.%d/service/endpoint", rnd.Intn(64), rnd.Intn(256), rnd.Intn(256))
+	}
+	return r, keys
+}
+
+func BenchmarkLongestPrefixBatch(b *testing.B) {
+	r, keys := batchBenchKeys()
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+
+	loop := func(keys []string) {
+		out := make([]Match, len(keys))
+		for j, k := range keys {
+			prefix, v, ok := r.LongestPrefixOK(k)
+			out[j] = Match{Key: k, Prefix: prefix, Value: v, Found: ok}
+		}
+	}
+
+	b.Run("loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			loop(keys)
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.LongestPrefixBatch(keys)
+		}
+	})
+	b.Run("batch-sorted", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.LongestPrefixBatchWith(keys, BatchOptions{Sorted: true})
+		}
+	})
+	b.Run("loop-presorted", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			loop(sorted)
+		}
+	})
+	b.Run("batch-sorted-presorted", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.LongestPrefixBatchWith(sorted, BatchOptions{Sorted: true})
+		}
+	})
+	b.Run("batch-workers", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.LongestPrefixBatchWith(keys, BatchOptions{Workers: 4})
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package radix
+
+import (
+	"github.com/pkg/errors"
+	"sync"
+)
+
+// loadCall is an in-flight or completed load of a key
+type loadCall struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// Loader wraps a Tree for concurrent use as a read-through
+// cache. All access to the tree goes through its lock and
+// concurrent loads of the same key are coalesced into one.
+// The wrapped tree must not be used directly while the
+// Loader is in use.
+type Loader struct {
+	mu    sync.Mutex
+	tree  *Tree
+	calls map[string]*loadCall
+}
+
+// NewLoader returns a Loader wrapping t
+func NewLoader(t *Tree) *Loader {
+	return &Loader{tree: t, calls: make(map[string]*loadCall)}
+}
+
+// Lookup is like Tree.Lookup, but safe for concurrent use
+func (l *Loader) Lookup(s string) (interface{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tree.Lookup(s)
+}
+
+// Insert is like Tree.Insert, but safe for concurrent use
+func (l *Loader) Insert(s string, v interface{}) (interface{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tree.Insert(s, v)
+}
+
+// Delete is like Tree.Delete, but safe for concurrent use
+func (l *Loader) Delete(s string) (interface{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tree.Delete(s)
+}
+
+// GetOrLoad is like Tree.GetOrLoad, but safe for concurrent use.
+// load runs without holding the lock, and callers asking for a
+// key while it is being loaded wait for that load and share its
+// result instead of calling load again.
+func (l *Loader) GetOrLoad(s string, load func(key string) (interface{}, error)) (interface{}, error) {
+	l.mu.Lock()
+	if v, ok := l.tree.Lookup(s); ok {
+		l.mu.Unlock()
+		return v, nil
+	}
+	if c, ok := l.calls[s]; ok {
+		l.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := &loadCall{}
+	c.wg.Add(1)
+	l.calls[s] = c
+	l.mu.Unlock()
+
+	l.doLoad(c, s, load)
+	return c.val, c.err
+}
+
+// doLoad runs load for a key and publishes its result,
+// even if load panics
+func (l *Loader) doLoad(c *loadCall, s string, load func(key string) (interface{}, error)) {
+	defer func() {
+		l.mu.Lock()
+		if c.err == nil {
+			l.tree.Insert(s, c.val)
+		}
+		delete(l.calls, s)
+		l.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.err = errors.Errorf("load of key %q panicked", s)
+	v, err := load(s)
+	if err != nil {
+		c.val, c.err = nil, errors.Wrapf(err, "can't load key %q", s)
+		return
+	}
+	c.val, c.err = v, nil
+}
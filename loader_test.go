@@ -0,0 +1,73 @@
+package radix
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLoaderCoalesce(t *testing.T) {
+	l := NewLoader(New())
+
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(key string) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return key + "!", nil
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	results := make([]interface{}, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = l.GetOrLoad("foo", load)
+		}(i)
+	}
+
+	<-started
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("bad load calls: %d", calls)
+	}
+	for i := 0; i < n; i++ {
+		if errs[i] != nil || results[i] != "foo!" {
+			t.Fatalf("bad result %d: %v %v", i, results[i], errs[i])
+		}
+	}
+	if v, ok := l.Lookup("foo"); !ok || v != "foo!" {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+}
+
+func TestLoaderError(t *testing.T) {
+	l := NewLoader(New())
+
+	calls := 0
+	load := func(key string) (interface{}, error) {
+		calls++
+		return nil, fmt.Errorf("backend failure")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.GetOrLoad("foo", load); err == nil {
+			t.Fatalf("expected error")
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("failed load was cached: %d", calls)
+	}
+	if _, ok := l.Lookup("foo"); ok {
+		t.Fatalf("failed load was inserted")
+	}
+}
@@ -0,0 +1,78 @@
+package radix
+
+// ConflictFn is used when merging trees to pick the value of a
+// key present in several of them. vals holds the values in the
+// order of the trees they come from and is only valid
+// during the call.
+type ConflictFn func(key string, vals []interface{}) interface{}
+
+// mergeCursor tracks the next entry of a tree being merged
+type mergeCursor struct {
+	tree *Tree
+	key  string
+	leaf *LeafNode
+}
+
+func (c *mergeCursor) advance() {
+	c.key, c.leaf = seek("", c.tree.root, c.key, false)
+}
+
+// MergeWalk walks several trees as a single sorted sequence.
+// Keys present in several trees are visited once with the
+// value from the first tree holding them.
+func MergeWalk(fn WalkFn, trees ...*Tree) {
+	MergeWalkFunc(fn, nil, trees...)
+}
+
+// MergeWalkFunc is like MergeWalk, but uses conflict to resolve
+// the value of keys present in several trees
+func MergeWalkFunc(fn WalkFn, conflict ConflictFn, trees ...*Tree) {
+	cursors := make([]*mergeCursor, 0, len(trees))
+	for _, t := range trees {
+		c := &mergeCursor{tree: t}
+		c.key, c.leaf = seek("", t.root, "", true)
+		if c.leaf != nil {
+			cursors = append(cursors, c)
+		}
+	}
+
+	var matched []*mergeCursor
+	var vals []interface{}
+	for len(cursors) > 0 {
+		// Collect the cursors positioned on the smallest key
+		matched = matched[:0]
+		for _, c := range cursors {
+			switch {
+			case len(matched) == 0 || c.key < matched[0].key:
+				matched = append(matched[:0], c)
+			case c.key == matched[0].key:
+				matched = append(matched, c)
+			}
+		}
+
+		key := matched[0].key
+		val := matched[0].leaf.val
+		if len(matched) > 1 && conflict != nil {
+			vals = vals[:0]
+			for _, c := range matched {
+				vals = append(vals, c.leaf.val)
+			}
+			val = conflict(key, vals)
+		}
+		if fn(key, val) {
+			return
+		}
+
+		// Move past the visited key, dropping exhausted trees
+		for _, c := range matched {
+			c.advance()
+		}
+		live := cursors[:0]
+		for _, c := range cursors {
+			if c.leaf != nil {
+				live = append(live, c)
+			}
+		}
+		cursors = live
+	}
+}
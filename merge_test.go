@@ -0,0 +1,58 @@
+package radix
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeWalk(t *testing.T) {
+	a := New()
+	a.Insert("", "a")
+	a.Insert("foo", "a")
+	a.Insert("foobar", "a")
+
+	b := New()
+	b.Insert("bar", "b")
+	b.Insert("foo", "b")
+	b.Insert("zip", "b")
+
+	c := New()
+	c.Insert("foo", "c")
+	c.Insert("foob", "c")
+
+	var keys []string
+	var vals []interface{}
+	MergeWalk(func(s string, v interface{}) bool {
+		keys = append(keys, s)
+		vals = append(vals, v)
+		return false
+	}, a, New(), b, c)
+
+	expectedKeys := []string{"", "bar", "foo", "foob", "foobar", "zip"}
+	expectedVals := []interface{}{"a", "b", "a", "c", "a", "b"}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Fatalf("mis-match: %v %v", keys, expectedKeys)
+	}
+	if !reflect.DeepEqual(vals, expectedVals) {
+		t.Fatalf("mis-match: %v %v", vals, expectedVals)
+	}
+
+	var conflicts []interface{}
+	keys = nil
+	MergeWalkFunc(func(s string, v interface{}) bool {
+		keys = append(keys, s)
+		return s == "foo"
+	}, func(key string, vals []interface{}) interface{} {
+		conflicts = append(conflicts, key, vals)
+		return vals[len(vals)-1]
+	}, a, b, c)
+
+	expectedKeys = []string{"", "bar", "foo"}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Fatalf("mis-match: %v %v", keys, expectedKeys)
+	}
+	expectedConflicts := []interface{}{"foo", []interface{}{"a", "b", "c"}}
+	if !reflect.DeepEqual(conflicts, expectedConflicts) {
+		t.Fatalf("mis-match: %v %v", conflicts, expectedConflicts)
+	}
+}
@@ -0,0 +1,50 @@
+package radix
+
+// Namespace is a view of a Tree restricted to the keys under
+// a prefix. Keys passed to a Namespace are relative to the
+// prefix, which is prepended on writes and stripped from the
+// keys passed to callbacks.
+type Namespace struct {
+	tree   *Tree
+	prefix string
+}
+
+// Namespace returns a view of the tree under prefix
+func (t *Tree) Namespace(prefix string) Namespace {
+	return Namespace{tree: t, prefix: prefix}
+}
+
+// Prefix returns the full prefix of the namespace
+func (ns Namespace) Prefix() string {
+	return ns.prefix
+}
+
+// Namespace returns a nested view under prefix
+func (ns Namespace) Namespace(prefix string) Namespace {
+	return Namespace{tree: ns.tree, prefix: ns.prefix + prefix}
+}
+
+// Insert is used to add a new entry or update an
+// existing entry in the namespace. Returns if updated.
+func (ns Namespace) Insert(s string, v interface{}) (interface{}, bool) {
+	return ns.tree.Insert(ns.prefix+s, v)
+}
+
+// Get is used to lookup a specific key in the namespace,
+// returning the value and if it was found
+func (ns Namespace) Get(s string) (interface{}, bool) {
+	return ns.tree.Lookup(ns.prefix + s)
+}
+
+// Delete is used to delete a key from the namespace,
+// returning the previous value and if it was deleted
+func (ns Namespace) Delete(s string) (interface{}, bool) {
+	return ns.tree.Delete(ns.prefix + s)
+}
+
+// Walk is used to walk all entries of the namespace
+func (ns Namespace) Walk(fn WalkFn) {
+	ns.tree.WalkPrefix(ns.prefix, func(s string, v interface{}) bool {
+		return fn(s[len(ns.prefix):], v)
+	})
+}
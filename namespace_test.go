@@ -0,0 +1,49 @@
+package radix
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	r := New()
+	r.Insert("other", 0)
+
+	acme := r.Namespace("tenant/acme/")
+	acme.Insert("foo", 1)
+	acme.Insert("foo/bar", 2)
+	r.Namespace("tenant/initech/").Insert("foo", 3)
+
+	if v, ok := r.Lookup("tenant/acme/foo"); !ok || v != 1 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if v, ok := acme.Get("foo/bar"); !ok || v != 2 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if _, ok := acme.Get("other"); ok {
+		t.Fatalf("key outside namespace found")
+	}
+
+	out := []string{}
+	acme.Walk(func(s string, v interface{}) bool {
+		out = append(out, s)
+		return false
+	})
+	sort.Strings(out)
+	expected := []string{"foo", "foo/bar"}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("mis-match: %v %v", out, expected)
+	}
+
+	nested := acme.Namespace("foo/")
+	if nested.Prefix() != "tenant/acme/foo/" {
+		t.Fatalf("bad prefix: %v", nested.Prefix())
+	}
+	if v, ok := nested.Delete("bar"); !ok || v != 2 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+	if r.Len() != 3 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+}
@@ -0,0 +1,15 @@
+package radix
+
+// Option configures optional Tree behavior. Options are
+// applied in order by NewWithOptions.
+type Option func(t *Tree)
+
+// NewWithOptions returns an empty Tree configured with
+// the given options
+func NewWithOptions(opts ...Option) *Tree {
+	t := &Tree{root: &Node{}, clock: systemClock{}, walCodec: gobCodec{}}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
@@ -0,0 +1,27 @@
+package radix
+
+import (
+	"testing"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	var applied []int
+	opt := func(i int) Option {
+		return func(*Tree) {
+			applied = append(applied, i)
+		}
+	}
+
+	r := NewWithOptions(opt(1), opt(2))
+	if r.Len() != 0 || r.Root() == nil {
+		t.Fatalf("bad tree: %v", r.Len())
+	}
+	if len(applied) != 2 || applied[0] != 1 || applied[1] != 2 {
+		t.Fatalf("bad option order: %v", applied)
+	}
+
+	r.Insert("foo", 1)
+	if v, ok := r.Get("foo"); !ok || v != 1 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+}
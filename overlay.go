@@ -0,0 +1,92 @@
+package radix
+
+// Overlay is a writable layer on top of a base Tree. Reads
+// consult the overlay first and fall back to the base, writes
+// only touch the overlay and deletions of base keys are
+// recorded as tombstones. The base is never modified until
+// Commit is called.
+type Overlay struct {
+	base       *Tree
+	top        *Tree
+	tombstones map[string]struct{}
+}
+
+// NewOverlay returns an empty Overlay on top of base
+func NewOverlay(base *Tree) *Overlay {
+	return &Overlay{
+		base:       base,
+		top:        New(),
+		tombstones: make(map[string]struct{}),
+	}
+}
+
+// Get is used to lookup a specific key through both layers,
+// returning the value and if it was found
+func (o *Overlay) Get(s string) (interface{}, bool) {
+	if v, ok := o.top.Lookup(s); ok {
+		return v, true
+	}
+	if _, ok := o.tombstones[s]; ok {
+		return nil, false
+	}
+	return o.base.Lookup(s)
+}
+
+// Insert is used to add or update an entry in the overlay.
+// Returns the previously visible value and if it was updated.
+func (o *Overlay) Insert(s string, v interface{}) (interface{}, bool) {
+	old, ok := o.Get(s)
+	o.top.Insert(s, v)
+	delete(o.tombstones, s)
+	return old, ok
+}
+
+// Delete is used to hide a key from the overlay, returning
+// the previously visible value and if it was deleted
+func (o *Overlay) Delete(s string) (interface{}, bool) {
+	old, ok := o.Get(s)
+	if !ok {
+		return nil, false
+	}
+	o.top.Delete(s)
+	if _, inBase := o.base.Lookup(s); inBase {
+		o.tombstones[s] = struct{}{}
+	}
+	return old, true
+}
+
+// LongestPrefix returns the longest visible key that is a
+// prefix of s, taking both layers and tombstones into account
+func (o *Overlay) LongestPrefix(s string) (string, interface{}, bool) {
+	topKey, topVal, topOK := o.top.LongestPrefixOK(s)
+
+	var baseKey string
+	var baseVal interface{}
+	var baseOK bool
+	keyLen, n := o.base.longestMatchFunc(o.base.root, s, func(key string) bool {
+		_, ok := o.tombstones[key]
+		return ok
+	})
+	if n != nil {
+		baseKey, baseVal, baseOK = s[:keyLen], n.leaf.val, true
+	}
+
+	if topOK && (!baseOK || len(topKey) >= len(baseKey)) {
+		return topKey, topVal, true
+	}
+	return baseKey, baseVal, baseOK
+}
+
+// Commit applies the overlay onto the base tree and
+// resets the overlay to empty
+func (o *Overlay) Commit() {
+	for k := range o.tombstones {
+		o.base.Delete(k)
+	}
+	o.top.Walk(o.top.Root(), "", func(k string, v interface{}) bool {
+		o.base.Insert(k, v)
+		return false
+	})
+	o.top = New()
+	o.tombstones = make(map[string]struct{})
+}
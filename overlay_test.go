@@ -0,0 +1,131 @@
+package radix
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOverlay(t *testing.T) {
+	base := New()
+	base.Insert("foo", 1)
+	base.Insert("foobar", 2)
+	base.Insert("zip", 3)
+
+	o := NewOverlay(base)
+	o.Insert("foobar", 20)
+	o.Insert("foobarbaz", 30)
+	if old, ok := o.Delete("zip"); !ok || old != 3 {
+		t.Fatalf("bad delete: %v %v", old, ok)
+	}
+	if _, ok := o.Delete("missing"); ok {
+		t.Fatalf("bad delete of missing key")
+	}
+
+	type exp struct {
+		inp string
+		val interface{}
+		ok  bool
+	}
+	cases := []exp{
+		{"foo", 1, true},
+		{"foobar", 20, true},
+		{"foobarbaz", 30, true},
+		{"zip", nil, false},
+		{"fo", nil, false},
+	}
+	for _, test := range cases {
+		v, ok := o.Get(test.inp)
+		if v != test.val || ok != test.ok {
+			t.Fatalf("mis-match %q: %v %v", test.inp, v, ok)
+		}
+	}
+
+	// Base is untouched
+	if v, ok := base.Lookup("foobar"); !ok || v != 2 {
+		t.Fatalf("base modified: %v %v", v, ok)
+	}
+	if _, ok := base.Lookup("zip"); !ok {
+		t.Fatalf("base modified")
+	}
+
+	// Re-inserting a deleted key clears the tombstone
+	o.Insert("zip", 4)
+	if v, ok := o.Get("zip"); !ok || v != 4 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+
+	o.Commit()
+	expected := map[string]interface{}{
+		"foo":       1,
+		"foobar":    20,
+		"foobarbaz": 30,
+		"zip":       4,
+	}
+	if out := base.ToMap(); !reflect.DeepEqual(out, expected) {
+		t.Fatalf("mis-match: %v %v", out, expected)
+	}
+}
+
+func TestOverlayLongestPrefix(t *testing.T) {
+	base := New()
+	base.Insert("foo", 1)
+	base.Insert("foobar", 2)
+	base.Insert("foobarbaz", 3)
+
+	o := NewOverlay(base)
+	o.Delete("foobarbaz")
+	o.Insert("foob", 10)
+
+	type exp struct {
+		inp string
+		out string
+		val interface{}
+		ok  bool
+	}
+	cases := []exp{
+		{"f", "", nil, false},
+		{"foo", "foo", 1, true},
+		{"fooba", "foob", 10, true},
+		{"foobarba", "foobar", 2, true},
+		{"foobarbazzip", "foobar", 2, true},
+	}
+	for _, test := range cases {
+		m, v, ok := o.LongestPrefix(test.inp)
+		if m != test.out || v != test.val || ok != test.ok {
+			t.Fatalf("mis-match: %q %v %v %v", m, v, ok, test)
+		}
+	}
+
+	o.Delete("foobar")
+	o.Delete("foob")
+	m, v, ok := o.LongestPrefix("foobarbaz")
+	if m != "foo" || v != 1 || !ok {
+		t.Fatalf("bad: %q %v %v", m, v, ok)
+	}
+}
+
+func TestOverlayExpiredBase(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	base := NewWithOptions(WithClock(clock))
+	base.InsertWithTTL("foo", 1, time.Second)
+	clock.Advance(time.Second)
+
+	o := NewOverlay(base)
+	if _, ok := o.Get("foo"); ok {
+		t.Fatalf("expected expired")
+	}
+	if base.Len() != 1 {
+		t.Fatalf("base modified: %v", base.Len())
+	}
+
+	base.Insert("f", 0)
+	m, v, ok := o.LongestPrefix("foobar")
+	if m != "f" || v != 0 || !ok {
+		t.Fatalf("bad: %q %v %v", m, v, ok)
+	}
+	o.Delete("f")
+	if m, v, ok = o.LongestPrefix("foobar"); ok {
+		t.Fatalf("bad: %q %v %v", m, v, ok)
+	}
+}
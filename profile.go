@@ -0,0 +1,85 @@
+package radix
+
+// Histogram maps a measured value to how many times it occurred
+type Histogram map[int]int
+
+// Count returns the number of samples in the histogram
+func (h Histogram) Count() int {
+	count := 0
+	for _, c := range h {
+		count += c
+	}
+	return count
+}
+
+// Max returns the largest sampled value, or 0 if empty
+func (h Histogram) Max() int {
+	max := 0
+	for v := range h {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+// Mean returns the average sampled value, or 0 if empty
+func (h Histogram) Mean() float64 {
+	sum, count := 0, 0
+	for v, c := range h {
+		sum += v * c
+		count += c
+	}
+	if count == 0 {
+		return 0
+	}
+	return float64(sum) / float64(count)
+}
+
+// Profile describes the shape of a tree
+type Profile struct {
+	// Nodes is the number of nodes, including the root
+	Nodes int `json:"nodes"`
+	// Entries is the number of stored values
+	Entries int `json:"entries"`
+	// KeyLengths is the histogram of stored key lengths
+	KeyLengths Histogram `json:"key_lengths"`
+	// PrefixLengths is the histogram of node prefix lengths
+	PrefixLengths Histogram `json:"prefix_lengths"`
+	// EdgesPerNode is the histogram of node fan-out
+	EdgesPerNode Histogram `json:"edges_per_node"`
+	// LeafDepths is the histogram of the depth of nodes holding
+	// a value, the root being at depth 0
+	LeafDepths Histogram `json:"leaf_depths"`
+}
+
+// Profile walks the tree and collects statistics about its shape
+func (t *Tree) Profile() Profile {
+	p := Profile{
+		KeyLengths:    make(Histogram),
+		PrefixLengths: make(Histogram),
+		EdgesPerNode:  make(Histogram),
+		LeafDepths:    make(Histogram),
+	}
+	profileRecursive(&p, t.root, 0, 0)
+	return p
+}
+
+// profileRecursive adds n and its subtree to the profile.
+// keyLen is the length of the key of the parent of n.
+func profileRecursive(p *Profile, n *Node, depth, keyLen int) {
+	keyLen += len(n.prefix)
+
+	p.Nodes++
+	p.PrefixLengths[len(n.prefix)]++
+	p.EdgesPerNode[len(n.edges)]++
+	if n.HasValue() {
+		p.Entries++
+		p.KeyLengths[keyLen]++
+		p.LeafDepths[depth]++
+	}
+
+	for _, e := range n.edges {
+		profileRecursive(p, e.node, depth+1, keyLen)
+	}
+}
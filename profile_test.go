@@ -0,0 +1,40 @@
+package radix
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	r := New()
+	for _, k := range []string{"", "foo", "foobar", "foobaz", "zip"} {
+		r.Insert(k, nil)
+	}
+
+	// Tree shape:
+	// "" -> "foo" -> "ba" -> "r"
+	//                     -> "z"
+	//    -> "zip"
+	p := r.Profile()
+
+	expected := Profile{
+		Nodes:         6,
+		Entries:       5,
+		KeyLengths:    Histogram{0: 1, 3: 2, 6: 2},
+		PrefixLengths: Histogram{0: 1, 1: 2, 2: 1, 3: 2},
+		EdgesPerNode:  Histogram{0: 3, 1: 1, 2: 2},
+		LeafDepths:    Histogram{0: 1, 1: 2, 3: 2},
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Fatalf("mis-match: %+v %+v", p, expected)
+	}
+
+	if p.KeyLengths.Count() != 5 || p.KeyLengths.Max() != 6 || p.KeyLengths.Mean() != 3.6 {
+		t.Fatalf("bad histogram: %v %v %v", p.KeyLengths.Count(), p.KeyLengths.Max(), p.KeyLengths.Mean())
+	}
+
+	if _, err := json.Marshal(p); err != nil {
+		t.Fatalf("can't marshal profile: %s", err)
+	}
+}
@@ -2,8 +2,11 @@ package radix
 
 import (
 	"github.com/pkg/errors"
+	"io"
+	"math/bits"
 	"sort"
 	"strings"
+	"time"
 )
 
 // VisitOrder order visiting order
@@ -25,6 +28,9 @@ type WalkFn func(s string, v interface{}) bool
 // LeafNode is used to represent a value
 type LeafNode struct {
 	val interface{}
+
+	// expiry is set for entries inserted with a TTL
+	expiry *expiryEntry
 }
 
 // NewLeafNode конструктор
@@ -170,22 +176,33 @@ func (e Edges) Sort() {
 type Tree struct {
 	root *Node
 	size int
+
+	clock     Clock
+	cleanup   CleanupPolicy
+	expiry    expiryHeap
+	nextSweep time.Time
+
+	wal      io.Writer
+	walCodec WALCodec
+	walErr   error
 }
 
-// New returns an empty Tree
+// New returns an empty Tree with default options
 func New() *Tree {
-	return NewFromMap(nil)
+	return NewWithOptions()
 }
 
 // NewFromRoot returns Tree from root node
 func NewFromRoot(root *Node) *Tree {
-	return &Tree{root: root}
+	t := NewWithOptions()
+	t.root = root
+	return t
 }
 
 // NewFromMap returns a new tree containing the keys
 // from an existing map
 func NewFromMap(m map[string]interface{}) *Tree {
-	t := &Tree{root: &Node{}}
+	t := NewWithOptions()
 	for k, v := range m {
 		t.Insert(k, v)
 	}
@@ -198,14 +215,20 @@ func (t *Tree) Len() int {
 }
 
 // longestPrefix finds the length of the shared prefix
-// of two strings
+// of two strings. Compares 8 bytes at a time, then
+// byte by byte for the tail.
 func longestPrefix(k1, k2 string) int {
 	max := len(k1)
 	if l := len(k2); l < max {
 		max = l
 	}
 	var i int
-	for i = 0; i < max; i++ {
+	for ; i+8 <= max; i += 8 {
+		if x := load64(k1, i) ^ load64(k2, i); x != 0 {
+			return i + bits.TrailingZeros64(x)/8
+		}
+	}
+	for ; i < max; i++ {
 		if k1[i] != k2[i] {
 			break
 		}
@@ -213,9 +236,27 @@ func longestPrefix(k1, k2 string) int {
 	return i
 }
 
+// load64 reads 8 bytes of s starting at i as a little
+// endian word, so the first differing byte of two words
+// is their lowest differing bit
+func load64(s string, i int) uint64 {
+	s = s[i : i+8]
+	return uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24 |
+		uint64(s[4])<<32 | uint64(s[5])<<40 | uint64(s[6])<<48 | uint64(s[7])<<56
+}
+
 // Insert is used to add a newentry or update
 // an existing entry. Returns if updated.
 func (t *Tree) Insert(s string, v interface{}) (interface{}, bool) {
+	t.maybeSweep()
+
+	old, updated := t.insert(s, v)
+	t.logInsert(s, v)
+	return old, updated
+}
+
+// insert does the insertion of a key
+func (t *Tree) insert(s string, v interface{}) (interface{}, bool) {
 	var parent *Node
 	n := t.root
 	search := s
@@ -225,6 +266,7 @@ func (t *Tree) Insert(s string, v interface{}) (interface{}, bool) {
 			if n.HasValue() {
 				old := n.leaf.val
 				n.leaf.val = v
+				t.clearExpiry(n.leaf)
 				return old, true
 			}
 
@@ -303,6 +345,45 @@ func (t *Tree) Insert(s string, v interface{}) (interface{}, bool) {
 // Delete is used to delete a key, returning the previous
 // value and if it was deleted
 func (t *Tree) Delete(s string) (interface{}, bool) {
+	old, deleted := t.deleteIf(s, nil)
+	if deleted {
+		t.logDelete(walDelete, s)
+	}
+	return old, deleted
+}
+
+// CompareAndDelete deletes a key only if its current value
+// is equal to old. Returns if it was deleted. The values
+// must be comparable.
+func (t *Tree) CompareAndDelete(s string, old interface{}) bool {
+	return t.DeleteIf(s, func(v interface{}) bool {
+		return v == old
+	})
+}
+
+// DeleteIf deletes a key only if pred returns true for
+// its current value. Returns if it was deleted.
+func (t *Tree) DeleteIf(s string, pred func(v interface{}) bool) bool {
+	_, deleted := t.deleteIf(s, pred)
+	if deleted {
+		t.logDelete(walDelete, s)
+	}
+	return deleted
+}
+
+// deleteIf does the deletion of a key in a single descent,
+// checking pred against the current value if it is set.
+// Expired entries are treated as missing, like in Lookup.
+func (t *Tree) deleteIf(s string, pred func(v interface{}) bool) (interface{}, bool) {
+	return t.deleteLeaf(s, func(l *LeafNode) bool {
+		return !t.expired(l) && (pred == nil || pred(l.val))
+	})
+}
+
+// deleteLeaf does the deletion of a key in a single descent,
+// only deleting the leaf if match returns true for it, or
+// unconditionally if match is nil
+func (t *Tree) deleteLeaf(s string, match func(l *LeafNode) bool) (interface{}, bool) {
 	var parent *Node
 	var label byte
 	n := t.root
@@ -310,7 +391,7 @@ func (t *Tree) Delete(s string) (interface{}, bool) {
 	for {
 		// Check for key exhaution
 		if len(search) == 0 {
-			if !n.HasValue() {
+			if !n.HasValue() || (match != nil && !match(n.leaf)) {
 				break
 			}
 			goto DELETE
@@ -338,6 +419,7 @@ DELETE:
 	leaf := n.leaf
 	n.leaf = nil
 	t.size--
+	t.clearExpiry(leaf)
 
 	// Check if we should delete this node from the parent
 	if parent != nil && len(n.edges) == 0 {
@@ -361,7 +443,11 @@ DELETE:
 // Returns how many nodes were deleted
 // Use this to delete large subtrees efficiently
 func (t *Tree) DeletePrefix(s string) int {
-	return t.deletePrefix(nil, t.root, s)
+	deleted := t.deletePrefix(nil, t.root, s)
+	if deleted > 0 {
+		t.logDelete(walDeletePrefix, s)
+	}
+	return deleted
 }
 
 // delete does a recursive deletion
@@ -371,9 +457,10 @@ func (t *Tree) deletePrefix(parent, n *Node, prefix string) int {
 		// Remove the leaf node
 		subTreeSize := 0
 		//recursively walk from all Edges of the node to be deleted
-		recursiveWalk(prefix, n, func(s string, v interface{}) bool {
+		t.VisitValues(n, func(key string, n *Node) error {
 			subTreeSize++
-			return false
+			t.clearExpiry(n.leaf)
+			return nil
 		})
 		if n.HasValue() {
 			n.leaf = nil
@@ -417,13 +504,22 @@ func (t *Tree) Root() *Node {
 	return t.root
 }
 
-// Find find key in tree
+// Find find key in tree. Descends from parent following s and
+// reports whether the search reached a node for exactly s
+// (isFound), how many bytes of s were consumed (prefixLen), the
+// deepest node holding a value whose key is a prefix of s
+// (lastLeafNode, nil if there is none) and the last node reached
+// (lastNode). Reaching a node does not mean a value is stored there,
+// use lastNode.HasValue() to tell the two apart.
 func (t *Tree) Find(parent *Node, s string) (isFound bool, prefixLen int, lastLeafNode *Node, lastNode *Node) {
 	n := parent
 	search := s
 	for {
 		// Check for key exhaution
 		if len(search) == 0 {
+			if n.HasValue() {
+				lastLeafNode = n
+			}
 			break
 		}
 
@@ -451,24 +547,122 @@ func (t *Tree) Find(parent *Node, s string) (isFound bool, prefixLen int, lastLe
 }
 
 // Get is used to lookup a specific key, returning
-// the value and if it was found
+// the value and if it was found. A node reached on
+// the way to other keys is reported as found, use
+// Lookup to only match stored keys.
 func (t *Tree) Get(s string) (interface{}, bool) {
 	isFound, _, _, lastNode := t.Find(t.Root(), s)
 	if !isFound {
 		return 0, false
 	}
+	if lastNode.HasValue() && t.expired(lastNode.leaf) {
+		return 0, false
+	}
+
+	return lastNode.Value(), true
+}
+
+// Lookup is used to lookup a specific key, returning
+// the value and if a value is stored under exactly that key.
+// On a miss the value is nil.
+func (t *Tree) Lookup(s string) (interface{}, bool) {
+	isFound, _, _, lastNode := t.Find(t.Root(), s)
+	if !isFound || !lastNode.HasValue() {
+		return nil, false
+	}
+	if t.expired(lastNode.leaf) {
+		return nil, false
+	}
 
 	return lastNode.Value(), true
 }
 
+// GetOrLoad is used to lookup a specific key, calling load to
+// produce and insert the value on a miss. Errors from load are
+// returned and nothing is inserted. The Tree is not safe for
+// concurrent use, see Loader to coalesce concurrent loads.
+func (t *Tree) GetOrLoad(s string, load func(key string) (interface{}, error)) (interface{}, error) {
+	if v, ok := t.Lookup(s); ok {
+		return v, nil
+	}
+
+	v, err := load(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't load key %q", s)
+	}
+
+	t.Insert(s, v)
+	return v, nil
+}
+
 // LongestPrefix is like Get, but instead of an
 // exact match, it will return the longest prefix match.
+// The result is only meaningful when every prefix on the
+// path holds a value, see LongestPrefixOK.
 func (t *Tree) LongestPrefix(s string) (string, interface{}, bool) {
 	_, prefixLen, _, lastLeafNode := t.Find(t.Root(), s)
 
 	return s[:prefixLen], lastLeafNode.Value(), true
 }
 
+// LongestPrefixOK is like Lookup, but instead of an
+// exact match, it will return the longest stored key
+// that is a prefix of s. Returns false if no stored key
+// is a prefix of s.
+func (t *Tree) LongestPrefixOK(s string) (string, interface{}, bool) {
+	keyLen, n := t.longestMatch(t.root, s)
+	if n == nil {
+		return "", nil, false
+	}
+
+	return s[:keyLen], n.leaf.val, true
+}
+
+// longestMatch finds the deepest node under parent holding an
+// unexpired value whose key is a prefix of s. Returns the length
+// of that key and the node, or a nil node if there is no match.
+func (t *Tree) longestMatch(parent *Node, s string) (int, *Node) {
+	return t.longestMatchFunc(parent, s, nil)
+}
+
+// longestMatchFunc is like longestMatch, but also ignores the
+// keys for which skip returns true, if it is set
+func (t *Tree) longestMatchFunc(parent *Node, s string, skip func(key string) bool) (int, *Node) {
+	var last *Node
+	var lastLen int
+	n := parent
+	search := s
+	for {
+		if n.HasValue() && !t.expired(n.leaf) {
+			keyLen := len(s) - len(search)
+			if skip == nil || !skip(s[:keyLen]) {
+				last = n
+				lastLen = keyLen
+			}
+		}
+
+		// Check for key exhaution
+		if len(search) == 0 {
+			break
+		}
+
+		// Look for an Edge
+		n = n.getEdge(search[0])
+		if n == nil {
+			break
+		}
+
+		// Consume the search prefix
+		if strings.HasPrefix(search, n.prefix) {
+			search = search[len(n.prefix):]
+		} else {
+			break
+		}
+	}
+
+	return lastLen, last
+}
+
 // Minimum is used to return the minimum value in the tree
 func (t *Tree) Minimum() (string, interface{}, bool) {
 	n := t.root
@@ -580,6 +774,79 @@ func (t *Tree) WalkPath(path string, fn WalkFn) {
 	}
 }
 
+// WalkMutable is used to walk the tree in key order while
+// allowing fn to modify it. Only the last visited key is kept
+// between steps and the next entry is looked up from it, so
+// every key is visited at most once, entries inserted after
+// the current key are visited and deleted ones are not.
+func (t *Tree) WalkMutable(fn WalkFn) {
+	t.WalkPrefixMutable("", fn)
+}
+
+// WalkPrefixMutable is like WalkMutable, but only visits
+// the entries under a prefix
+func (t *Tree) WalkPrefixMutable(prefix string, fn WalkFn) {
+	bound := prefix
+	inclusive := true
+	for {
+		k, leaf := seek("", t.root, bound, inclusive)
+		if leaf == nil || !strings.HasPrefix(k, prefix) {
+			return
+		}
+		if fn(k, leaf.val) {
+			return
+		}
+		bound = k
+		inclusive = false
+	}
+}
+
+// seek finds the smallest key in the subtree of n which is
+// greater than bound, or equal to it if inclusive is set.
+// acc is the key of the parent of n.
+func seek(acc string, n *Node, bound string, inclusive bool) (string, *LeafNode) {
+	k := acc + n.prefix
+	m := len(k)
+	if len(bound) < m {
+		m = len(bound)
+	}
+	if c := strings.Compare(k[:m], bound[:m]); c < 0 {
+		return "", nil
+	} else if c > 0 || len(k) > len(bound) {
+		// Every key in this subtree is past the bound
+		return subtreeMinimum(k, n)
+	}
+
+	// k is a prefix of bound
+	if len(k) == len(bound) && inclusive && n.leaf != nil {
+		return k, n.leaf
+	}
+	for _, e := range n.edges {
+		if len(bound) > len(k) && e.label < bound[len(k)] {
+			continue
+		}
+		if key, leaf := seek(k, e.node, bound, inclusive); leaf != nil {
+			return key, leaf
+		}
+	}
+	return "", nil
+}
+
+// subtreeMinimum returns the smallest key in the subtree
+// of n, where k is the key of n
+func subtreeMinimum(k string, n *Node) (string, *LeafNode) {
+	for {
+		if n.leaf != nil {
+			return k, n.leaf
+		}
+		if len(n.edges) == 0 {
+			return "", nil
+		}
+		n = n.edges[0].node
+		k += n.prefix
+	}
+}
+
 // recursiveWalk is used to do a pre-order walk of a node
 // recursively. Returns true if the walk should be aborted
 func recursiveWalk(prefix string, n *Node, fn WalkFn) bool {
@@ -634,6 +901,54 @@ func (t *Tree) VisitNodes(n *Node, order VisitOrder, fn func(*Node) error) error
 	return nil
 }
 
+// StructVisit describes a node and its position in the tree
+type StructVisit struct {
+	Node *Node
+	// Key is the full key of the node, made of the prefixes
+	// of the nodes from the root
+	Key string
+	// Depth is the number of edges from the root
+	Depth int
+	// Label is the label of the incoming Edge, 0 for the root
+	Label byte
+	// Children is the number of outgoing Edges
+	Children int
+	// Leaf is set if the node has no children
+	Leaf bool
+}
+
+// VisitStructure visits all nodes from the root down to the
+// leafs, passing each node along with its position in the tree
+func (t *Tree) VisitStructure(fn func(v StructVisit) error) error {
+	return t.visitStructureRecursive(t.root, "", 0, 0, fn)
+}
+
+// visitStructureRecursive visits n and its subtree. prefix is
+// the key of the parent of n and label the incoming Edge label.
+func (t *Tree) visitStructureRecursive(n *Node, prefix string, depth int, label byte, fn func(v StructVisit) error) error {
+	key := prefix + n.prefix
+	err := fn(StructVisit{
+		Node:     n,
+		Key:      key,
+		Depth:    depth,
+		Label:    label,
+		Children: len(n.edges),
+		Leaf:     len(n.edges) == 0,
+	})
+	if err != nil {
+		return errors.Wrap(err, "can't process node")
+	}
+
+	for i := range n.edges {
+		err := t.visitStructureRecursive(n.edges[i].node, key, depth+1, n.edges[i].label, fn)
+		if err != nil {
+			return errors.Wrap(err, "can't traverse inner nodes")
+		}
+	}
+
+	return nil
+}
+
 // VisitValues visits all nodes with values
 func (t *Tree) VisitValues(parent *Node, fn func(key string, n *Node) error) error {
 	return t.visitValuesRecursive(parent, "", fn)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -451,3 +452,386 @@ func TestVisitValues(t *testing.T) {
 		t.Fatalf("mis-match: %v %v", out, expected)
 	}
 }
+
+func TestLookup(t *testing.T) {
+	r := New()
+	r.Insert("foobar", 1)
+	r.Insert("foozip", 2)
+
+	cases := []struct {
+		inp string
+		out interface{}
+		ok  bool
+	}{
+		{"foobar", 1, true},
+		{"foozip", 2, true},
+		{"foo", nil, false},
+		{"", nil, false},
+		{"foob", nil, false},
+		{"foobarbaz", nil, false},
+	}
+	for _, test := range cases {
+		v, ok := r.Lookup(test.inp)
+		if ok != test.ok || v != test.out {
+			t.Fatalf("mis-match %q: %v %v", test.inp, v, ok)
+		}
+	}
+}
+
+func TestLongestPrefixOK(t *testing.T) {
+	r := New()
+	r.Insert("foo", 1)
+	r.Insert("foobarbaz", 2)
+	r.Insert("foozip", 3)
+
+	type exp struct {
+		inp string
+		out string
+		val interface{}
+		ok  bool
+	}
+	cases := []exp{
+		{"", "", nil, false},
+		{"a", "", nil, false},
+		{"fo", "", nil, false},
+		{"foo", "foo", 1, true},
+		{"foobar", "foo", 1, true},
+		{"foobarba", "foo", 1, true},
+		{"foobarbaz", "foobarbaz", 2, true},
+		{"foobarbazzip", "foobarbaz", 2, true},
+		{"foozi", "foo", 1, true},
+		{"foozipzap", "foozip", 3, true},
+	}
+	for _, test := range cases {
+		m, v, ok := r.LongestPrefixOK(test.inp)
+		if m != test.out || v != test.val || ok != test.ok {
+			t.Fatalf("mis-match: %q %v %v %v", m, v, ok, test)
+		}
+	}
+
+	r.Insert("", 0)
+	m, v, ok := r.LongestPrefixOK("abc")
+	if m != "" || v != 0 || !ok {
+		t.Fatalf("bad: %q %v %v", m, v, ok)
+	}
+}
+
+func TestFindLastLeafNode(t *testing.T) {
+	r := New()
+	r.Insert("foo", 1)
+	r.Insert("foobar", 2)
+
+	isFound, prefixLen, leaf, last := r.Find(r.Root(), "foobar")
+	if !isFound || prefixLen != 6 || leaf == nil || leaf.Value() != 2 || last != leaf {
+		t.Fatalf("bad: %v %v %v %v", isFound, prefixLen, leaf, last)
+	}
+
+	isFound, _, leaf, _ = r.Find(r.Root(), "fo")
+	if isFound || leaf != nil {
+		t.Fatalf("bad: %v %v", isFound, leaf)
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	r := New()
+	r.Insert("foo", 1)
+
+	calls := 0
+	load := func(key string) (interface{}, error) {
+		calls++
+		if key == "bad" {
+			return nil, fmt.Errorf("backend failure")
+		}
+		return len(key), nil
+	}
+
+	v, err := r.GetOrLoad("foo", load)
+	if err != nil || v != 1 || calls != 0 {
+		t.Fatalf("bad: %v %v %d", v, err, calls)
+	}
+
+	v, err = r.GetOrLoad("foobar", load)
+	if err != nil || v != 6 || calls != 1 {
+		t.Fatalf("bad: %v %v %d", v, err, calls)
+	}
+	v, err = r.GetOrLoad("foobar", load)
+	if err != nil || v != 6 || calls != 1 {
+		t.Fatalf("bad: %v %v %d", v, err, calls)
+	}
+
+	if _, err = r.GetOrLoad("bad", load); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, ok := r.Lookup("bad"); ok {
+		t.Fatalf("failed load was inserted")
+	}
+	if r.Len() != 2 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+}
+
+func TestWalkMutable(t *testing.T) {
+	r := New()
+	keys := []string{"", "a", "ab", "abc", "b", "ba", "c"}
+	for _, k := range keys {
+		r.Insert(k, nil)
+	}
+
+	out := []string{}
+	r.WalkMutable(func(s string, v interface{}) bool {
+		out = append(out, s)
+		switch s {
+		case "a":
+			// Deleting visited and upcoming keys, forcing merges
+			r.Delete("")
+			r.Delete("ab")
+			r.Insert("aa", nil)
+		case "abc":
+			// Inserting behind and ahead of the cursor
+			r.Insert("0", nil)
+			r.Insert("bb", nil)
+			r.Delete("b")
+		}
+		return false
+	})
+
+	expected := []string{"", "a", "aa", "abc", "ba", "bb", "c"}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("mis-match: %v %v", out, expected)
+	}
+}
+
+func TestWalkPrefixMutable(t *testing.T) {
+	r := New()
+	keys := []string{"foo", "foo/bar", "foo/baz", "foobar", "zip"}
+	for _, k := range keys {
+		r.Insert(k, nil)
+	}
+
+	out := []string{}
+	r.WalkPrefixMutable("foo/", func(s string, v interface{}) bool {
+		out = append(out, s)
+		r.Delete(s)
+		return false
+	})
+
+	expected := []string{"foo/bar", "foo/baz"}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("mis-match: %v %v", out, expected)
+	}
+	if r.Len() != 3 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+
+	out = []string{}
+	r.WalkPrefixMutable("fo", func(s string, v interface{}) bool {
+		out = append(out, s)
+		return s == "foo"
+	})
+	expected = []string{"foo"}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("mis-match: %v %v", out, expected)
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	r := New()
+	r.Insert("foo", 1)
+	r.Insert("foobar", 2)
+	r.Insert("foobaz", 3)
+
+	if r.CompareAndDelete("foobar", 1) {
+		t.Fatalf("deleted with stale value")
+	}
+	if r.CompareAndDelete("fooba", nil) {
+		t.Fatalf("deleted missing key")
+	}
+	if !r.CompareAndDelete("foobar", 2) {
+		t.Fatalf("not deleted")
+	}
+	if _, ok := r.Lookup("foobar"); ok {
+		t.Fatalf("still present")
+	}
+
+	if r.DeleteIf("foobaz", func(v interface{}) bool { return v.(int) > 3 }) {
+		t.Fatalf("deleted with false predicate")
+	}
+	if !r.DeleteIf("foobaz", func(v interface{}) bool { return v.(int) == 3 }) {
+		t.Fatalf("not deleted")
+	}
+
+	expected := map[string]interface{}{"foo": 1}
+	if out := r.ToMap(); !reflect.DeepEqual(out, expected) {
+		t.Fatalf("mis-match: %v %v", out, expected)
+	}
+	if r.Len() != 1 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+}
+
+func TestLongestPrefixLength(t *testing.T) {
+	base := "0123456789abcdefghijklmnopqrstuv"
+	for n := 0; n <= len(base); n++ {
+		k1 := base[:n]
+		if l := longestPrefix(k1, base); l != n {
+			t.Fatalf("bad prefix of %q: %d", k1, l)
+		}
+
+		for i := 0; i < n; i++ {
+			k2 := k1[:i] + "#" + k1[i+1:]
+			if l := longestPrefix(k1, k2); l != i {
+				t.Fatalf("bad prefix of %q %q: %d", k1, k2, l)
+			}
+		}
+	}
+}
+
+func TestVisitStructure(t *testing.T) {
+	r := New()
+
+	s := []string{"", "foo", "foobar", "foobaz", "zip"}
+
+	for _, ss := range s {
+		r.Insert(ss, true)
+	}
+
+	var out []StructVisit
+	err := r.VisitStructure(func(v StructVisit) error {
+		v.Node = nil
+		out = append(out, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("can't visit structure: %s", err)
+	}
+
+	expected := []StructVisit{
+		{Key: "", Depth: 0, Label: 0, Children: 2},
+		{Key: "foo", Depth: 1, Label: 'f', Children: 1},
+		{Key: "fooba", Depth: 2, Label: 'b', Children: 2},
+		{Key: "foobar", Depth: 3, Label: 'r', Leaf: true},
+		{Key: "foobaz", Depth: 3, Label: 'z', Leaf: true},
+		{Key: "zip", Depth: 1, Label: 'z', Leaf: true},
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("mis-match: %v %v", out, expected)
+	}
+
+	err = r.VisitStructure(func(v StructVisit) error {
+		if v.Key == "fooba" {
+			return fmt.Errorf("stop")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+// longestPrefixBytes is the byte by byte longestPrefix,
+// kept as a reference for benchmarks
+func longestPrefixBytes(k1, k2 string) int {
+	max := len(k1)
+	if l := len(k2); l < max {
+		max = l
+	}
+	var i int
+	for i = 0; i < max; i++ {
+		if k1[i] != k2[i] {
+			break
+		}
+	}
+	return i
+}
+
+var benchSink int
+
+func BenchmarkLongestPrefix(b *testing.B) {
+	for _, n := range []int{4, 16, 64} {
+		k1 := strings.Repeat("x", n) + "a"
+		k2 := strings.Repeat("x", n) + "b"
+		b.Run(fmt.Sprintf("word/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				benchSink += longestPrefix(k1, k2)
+			}
+		})
+		b.Run(fmt.Sprintf("bytes/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				benchSink += longestPrefixBytes(k1, k2)
+			}
+		})
+	}
+}
+
+// BenchmarkHasPrefix compares strings.HasPrefix, used by the
+// lookup paths, with a check built on longestPrefix
+func BenchmarkHasPrefix(b *testing.B) {
+	for _, n := range []int{4, 16, 64} {
+		s := strings.Repeat("x", n) + "/tail"
+		prefix := strings.Repeat("x", n)
+		b.Run(fmt.Sprintf("strings/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if strings.HasPrefix(s, prefix) {
+					benchSink++
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("word/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if len(s) >= len(prefix) && longestPrefix(s, prefix) == len(prefix) {
+					benchSink++
+				}
+			}
+		})
+	}
+}
+
+// benchTree builds a tree of UUID keys under a few shared prefixes
+func benchTree() (*Tree, []string) {
+	r := New()
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("/service/%d/instance/%s", i%16, generateUUID())
+		r.Insert(keys[i], i)
+	}
+	return r, keys
+}
+
+func BenchmarkGet(b *testing.B) {
+	r, keys := benchTree()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := r.Get(keys[i%len(keys)]); ok {
+			benchSink++
+		}
+	}
+}
+
+func BenchmarkLongestPrefixOK(b *testing.B) {
+	r, keys := benchTree()
+	for i := range keys {
+		keys[i] += "/path/below"
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, ok := r.LongestPrefixOK(keys[i%len(keys)]); ok {
+			benchSink++
+		}
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	_, keys := benchTree()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%len(keys) == 0 {
+			b.StopTimer()
+			r := New()
+			b.StartTimer()
+			benchInsertTree = r
+		}
+		benchInsertTree.Insert(keys[i%len(keys)], i)
+	}
+}
+
+var benchInsertTree *Tree
@@ -0,0 +1,180 @@
+// Package radixkey encodes numbers and tuples into strings whose
+// lexicographic order matches the order of the encoded values,
+// so they can be used as radix tree keys and iterated in order.
+package radixkey
+
+import (
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"math"
+)
+
+const signBit = 1 << 63
+
+// String components are terminated by escTerm and zero
+// bytes inside them are escaped as escZero, keeping
+// shorter strings ordered before longer ones
+const (
+	escByte = 0x00
+	escTerm = 0x01
+	escZero = 0xff
+)
+
+// AppendUint64 appends the order-preserving encoding of v
+func AppendUint64(dst []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(dst, buf[:]...)
+}
+
+// AppendInt64 appends the order-preserving encoding of v.
+// Negative values are ordered before positive ones.
+func AppendInt64(dst []byte, v int64) []byte {
+	return AppendUint64(dst, uint64(v)^signBit)
+}
+
+// AppendFloat64 appends the order-preserving encoding of v.
+// Negative zero is encoded as zero. All NaN values, whatever
+// their sign and payload, are encoded as the same NaN, ordered
+// after positive infinity.
+func AppendFloat64(dst []byte, v float64) []byte {
+	if math.IsNaN(v) {
+		v = math.NaN()
+	} else if v == 0 {
+		v = 0
+	}
+	bits := math.Float64bits(v)
+	if bits&signBit != 0 {
+		bits = ^bits
+	} else {
+		bits |= signBit
+	}
+	return AppendUint64(dst, bits)
+}
+
+// AppendString appends the order-preserving encoding of s as
+// a tuple component. Unlike a raw string it is terminated, so
+// components following it do not affect its order.
+func AppendString(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if s[i] == escByte {
+			dst = append(dst, escByte, escZero)
+			continue
+		}
+		dst = append(dst, s[i])
+	}
+	return append(dst, escByte, escTerm)
+}
+
+// Uint64 returns the order-preserving encoding of v
+func Uint64(v uint64) string {
+	return string(AppendUint64(nil, v))
+}
+
+// Int64 returns the order-preserving encoding of v
+func Int64(v int64) string {
+	return string(AppendInt64(nil, v))
+}
+
+// Float64 returns the order-preserving encoding of v
+func Float64(v float64) string {
+	return string(AppendFloat64(nil, v))
+}
+
+// Tuple returns the order-preserving encoding of a composite
+// key, ordered by its first part, then its second and so on.
+// Parts may be uint64, int64, int, float64 or string values.
+func Tuple(parts ...interface{}) (string, error) {
+	var dst []byte
+	for i, part := range parts {
+		switch v := part.(type) {
+		case uint64:
+			dst = AppendUint64(dst, v)
+		case int64:
+			dst = AppendInt64(dst, v)
+		case int:
+			dst = AppendInt64(dst, int64(v))
+		case float64:
+			dst = AppendFloat64(dst, v)
+		case string:
+			dst = AppendString(dst, v)
+		default:
+			return "", errors.Errorf("unsupported type %T of part %d", part, i)
+		}
+	}
+	return string(dst), nil
+}
+
+// Decoder reads back the components of an encoded key
+// in the order they were appended
+type Decoder struct {
+	key string
+}
+
+// NewDecoder returns a Decoder reading from key
+func NewDecoder(key string) *Decoder {
+	return &Decoder{key: key}
+}
+
+// Len returns the number of bytes left to decode
+func (d *Decoder) Len() int {
+	return len(d.key)
+}
+
+// Uint64 decodes a component encoded by AppendUint64
+func (d *Decoder) Uint64() (uint64, error) {
+	if len(d.key) < 8 {
+		return 0, errors.Errorf("can't decode uint64 from %d bytes", len(d.key))
+	}
+	v := binary.BigEndian.Uint64([]byte(d.key[:8]))
+	d.key = d.key[8:]
+	return v, nil
+}
+
+// Int64 decodes a component encoded by AppendInt64
+func (d *Decoder) Int64() (int64, error) {
+	v, err := d.Uint64()
+	if err != nil {
+		return 0, errors.Wrap(err, "can't decode int64")
+	}
+	return int64(v ^ signBit), nil
+}
+
+// Float64 decodes a component encoded by AppendFloat64
+func (d *Decoder) Float64() (float64, error) {
+	bits, err := d.Uint64()
+	if err != nil {
+		return 0, errors.Wrap(err, "can't decode float64")
+	}
+	if bits&signBit != 0 {
+		bits &^= signBit
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits), nil
+}
+
+// String decodes a component encoded by AppendString
+func (d *Decoder) String() (string, error) {
+	out := make([]byte, 0, len(d.key))
+	for i := 0; i < len(d.key); i++ {
+		if d.key[i] != escByte {
+			out = append(out, d.key[i])
+			continue
+		}
+		if i+1 == len(d.key) {
+			break
+		}
+		switch d.key[i+1] {
+		case escTerm:
+			d.key = d.key[i+2:]
+			return string(out), nil
+		case escZero:
+			out = append(out, escByte)
+			i++
+		default:
+			return "", errors.Errorf("invalid escape 0x%02x at offset %d", d.key[i+1], i+1)
+		}
+	}
+	return "", errors.New("unterminated string")
+}
@@ -0,0 +1,140 @@
+package radixkey
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestInt64Order(t *testing.T) {
+	inp := []int64{math.MinInt64, -1 << 40, -256, -1, 0, 1, 255, 256, 1 << 40, math.MaxInt64}
+	for i := 1; i < len(inp); i++ {
+		if Int64(inp[i-1]) >= Int64(inp[i]) {
+			t.Fatalf("bad order: %v %v", inp[i-1], inp[i])
+		}
+	}
+
+	for _, v := range inp {
+		out, err := NewDecoder(Int64(v)).Int64()
+		if err != nil || out != v {
+			t.Fatalf("bad round trip: %v %v %v", v, out, err)
+		}
+	}
+}
+
+func TestUint64Order(t *testing.T) {
+	inp := []uint64{0, 1, 255, 256, 1 << 40, math.MaxUint64}
+	for i := 1; i < len(inp); i++ {
+		if Uint64(inp[i-1]) >= Uint64(inp[i]) {
+			t.Fatalf("bad order: %v %v", inp[i-1], inp[i])
+		}
+	}
+
+	for _, v := range inp {
+		out, err := NewDecoder(Uint64(v)).Uint64()
+		if err != nil || out != v {
+			t.Fatalf("bad round trip: %v %v %v", v, out, err)
+		}
+	}
+}
+
+func TestFloat64Order(t *testing.T) {
+	inp := []float64{math.Inf(-1), -1e300, -2.5, -1, -math.SmallestNonzeroFloat64, 0, math.SmallestNonzeroFloat64, 1, 2.5, 1e300, math.Inf(1)}
+	for i := 1; i < len(inp); i++ {
+		if Float64(inp[i-1]) >= Float64(inp[i]) {
+			t.Fatalf("bad order: %v %v", inp[i-1], inp[i])
+		}
+	}
+
+	for _, v := range inp {
+		out, err := NewDecoder(Float64(v)).Float64()
+		if err != nil || out != v {
+			t.Fatalf("bad round trip: %v %v %v", v, out, err)
+		}
+	}
+
+	// Negative zero is the same key as zero
+	negZero := math.Copysign(0, -1)
+	if Float64(negZero) != Float64(0) {
+		t.Fatalf("negative zero not canonical")
+	}
+	out, err := NewDecoder(Float64(negZero)).Float64()
+	if err != nil || out != 0 || math.Signbit(out) {
+		t.Fatalf("bad round trip: %v %v", out, err)
+	}
+}
+
+func TestFloat64NaN(t *testing.T) {
+	nans := []float64{math.NaN(), math.Copysign(math.NaN(), -1), math.Float64frombits(0xfff0000000000001)}
+	for _, nan := range nans {
+		if Float64(nan) <= Float64(math.Inf(1)) {
+			t.Fatalf("bad order: %x", math.Float64bits(nan))
+		}
+		if Float64(nan) != Float64(math.NaN()) {
+			t.Fatalf("not canonical: %x", math.Float64bits(nan))
+		}
+		out, err := NewDecoder(Float64(nan)).Float64()
+		if err != nil || !math.IsNaN(out) {
+			t.Fatalf("bad round trip: %v %v", out, err)
+		}
+	}
+}
+
+func TestTuple(t *testing.T) {
+	type tuple struct {
+		name string
+		ts   int64
+	}
+	inp := []tuple{
+		{"", 5},
+		{"a", -1},
+		{"a", 3},
+		{"a\x00", -5},
+		{"a\x00b", 0},
+		{"ab", -10},
+		{"b", math.MinInt64},
+	}
+
+	keys := make([]string, len(inp))
+	for i, tt := range inp {
+		k, err := Tuple(tt.name, tt.ts)
+		if err != nil {
+			t.Fatalf("can't encode: %s", err)
+		}
+		keys[i] = k
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Fatalf("bad order: %q", keys)
+	}
+
+	for i, k := range keys {
+		d := NewDecoder(k)
+		name, err := d.String()
+		if err != nil {
+			t.Fatalf("can't decode: %s", err)
+		}
+		ts, err := d.Int64()
+		if err != nil {
+			t.Fatalf("can't decode: %s", err)
+		}
+		if name != inp[i].name || ts != inp[i].ts || d.Len() != 0 {
+			t.Fatalf("bad round trip: %q %v %v", name, ts, inp[i])
+		}
+	}
+
+	if _, err := Tuple(struct{}{}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	if _, err := NewDecoder("short").Uint64(); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := NewDecoder("abc").String(); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := NewDecoder("a\x00\x02").String(); err == nil {
+		t.Fatalf("expected error")
+	}
+}
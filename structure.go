@@ -0,0 +1,69 @@
+package radix
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+)
+
+// structureNode is the JSON form of a node used by MarshalStructure.
+// JSON strings can't hold invalid UTF-8, so the prefix and key are
+// also emitted as raw bytes, which encoding/json renders as base64.
+type structureNode struct {
+	Prefix      string           `json:"prefix"`
+	PrefixBytes []byte           `json:"prefix_bytes"`
+	Key         string           `json:"key"`
+	KeyBytes    []byte           `json:"key_bytes"`
+	Depth       int              `json:"depth"`
+	HasValue    bool             `json:"has_value"`
+	Value       interface{}      `json:"value,omitempty"`
+	Count       int              `json:"count"`
+	Children    []*structureNode `json:"children"`
+}
+
+// MarshalStructure encodes the shape of the tree as nested JSON
+// objects, one per node, holding the node prefix, its full key,
+// depth, whether it holds a value, the number of values in its
+// subtree and its children. The prefix and key strings have
+// invalid UTF-8 replaced, prefix_bytes and key_bytes hold them
+// exactly, base64 encoded. Values themselves are omitted, see
+// MarshalStructureFunc.
+func (t *Tree) MarshalStructure() ([]byte, error) {
+	return t.MarshalStructureFunc(nil)
+}
+
+// MarshalStructureFunc is like MarshalStructure, but includes
+// the values of nodes as returned by render
+func (t *Tree) MarshalStructureFunc(render func(v interface{}) interface{}) ([]byte, error) {
+	out, err := json.Marshal(buildStructure(t.root, "", 0, render))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't marshal structure")
+	}
+	return out, nil
+}
+
+// buildStructure converts the subtree of n to its JSON form.
+// prefix is the key of the parent of n.
+func buildStructure(n *Node, prefix string, depth int, render func(v interface{}) interface{}) *structureNode {
+	s := &structureNode{
+		Prefix:      n.prefix,
+		PrefixBytes: []byte(n.prefix),
+		Key:         prefix + n.prefix,
+		Depth:       depth,
+		HasValue:    n.HasValue(),
+		Children:    make([]*structureNode, 0, len(n.edges)),
+	}
+	s.KeyBytes = []byte(s.Key)
+	if s.HasValue {
+		s.Count++
+		if render != nil {
+			s.Value = render(n.leaf.val)
+		}
+	}
+
+	for _, e := range n.edges {
+		child := buildStructure(e.node, s.Key, depth+1, render)
+		s.Count += child.Count
+		s.Children = append(s.Children, child)
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package radix
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/armon/go-radix/radixkey"
+	"testing"
+)
+
+func TestMarshalStructure(t *testing.T) {
+	r := New()
+	r.Insert("foo", 1)
+	r.Insert("foobar", 2)
+	r.Insert("foobaz", 3)
+
+	out, err := r.MarshalStructure()
+	if err != nil {
+		t.Fatalf("can't marshal: %s", err)
+	}
+	expected := `{"prefix":"","prefix_bytes":"","key":"","key_bytes":"","depth":0,"has_value":false,"count":3,"children":[` +
+		`{"prefix":"foo","prefix_bytes":"Zm9v","key":"foo","key_bytes":"Zm9v","depth":1,"has_value":true,"count":3,"children":[` +
+		`{"prefix":"ba","prefix_bytes":"YmE=","key":"fooba","key_bytes":"Zm9vYmE=","depth":2,"has_value":false,"count":2,"children":[` +
+		`{"prefix":"r","prefix_bytes":"cg==","key":"foobar","key_bytes":"Zm9vYmFy","depth":3,"has_value":true,"count":1,"children":[]},` +
+		`{"prefix":"z","prefix_bytes":"eg==","key":"foobaz","key_bytes":"Zm9vYmF6","depth":3,"has_value":true,"count":1,"children":[]}]}]}]}`
+	if string(out) != expected {
+		t.Fatalf("mis-match:\n%s\n%s", out, expected)
+	}
+
+	out, err = r.MarshalStructureFunc(func(v interface{}) interface{} {
+		return fmt.Sprintf("v%d", v)
+	})
+	if err != nil {
+		t.Fatalf("can't marshal: %s", err)
+	}
+	expected = `{"prefix":"","prefix_bytes":"","key":"","key_bytes":"","depth":0,"has_value":false,"count":3,"children":[` +
+		`{"prefix":"foo","prefix_bytes":"Zm9v","key":"foo","key_bytes":"Zm9v","depth":1,"has_value":true,"value":"v1","count":3,"children":[` +
+		`{"prefix":"ba","prefix_bytes":"YmE=","key":"fooba","key_bytes":"Zm9vYmE=","depth":2,"has_value":false,"count":2,"children":[` +
+		`{"prefix":"r","prefix_bytes":"cg==","key":"foobar","key_bytes":"Zm9vYmFy","depth":3,"has_value":true,"value":"v2","count":1,"children":[]},` +
+		`{"prefix":"z","prefix_bytes":"eg==","key":"foobaz","key_bytes":"Zm9vYmF6","depth":3,"has_value":true,"value":"v3","count":1,"children":[]}]}]}]}`
+	if string(out) != expected {
+		t.Fatalf("mis-match:\n%s\n%s", out, expected)
+	}
+}
+
+func TestMarshalStructureBinaryKeys(t *testing.T) {
+	r := New()
+	for _, v := range []int64{-1, 0, 1, 255, 1 << 40} {
+		r.Insert(radixkey.Int64(v), v)
+	}
+
+	out, err := r.MarshalStructure()
+	if err != nil {
+		t.Fatalf("can't marshal: %s", err)
+	}
+
+	type node struct {
+		KeyBytes []byte  `json:"key_bytes"`
+		HasValue bool    `json:"has_value"`
+		Children []*node `json:"children"`
+	}
+	var root node
+	if err := json.Unmarshal(out, &root); err != nil {
+		t.Fatalf("can't unmarshal: %s", err)
+	}
+
+	found := 0
+	var check func(n *node)
+	check = func(n *node) {
+		if n.HasValue {
+			if _, ok := r.Lookup(string(n.KeyBytes)); !ok {
+				t.Fatalf("key not found: %x", n.KeyBytes)
+			}
+			found++
+		}
+		for _, c := range n.Children {
+			check(c)
+		}
+	}
+	check(&root)
+	if found != r.Len() {
+		t.Fatalf("bad count: %v %v", found, r.Len())
+	}
+}
@@ -0,0 +1,177 @@
+package radix
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Clock is used to read the current time when
+// handling entries inserted with a TTL
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock backed by time.Now
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// CleanupPolicy controls how expired entries are removed.
+// Expired entries are always hidden from Get, Lookup and
+// LongestPrefixOK, but reads never modify the tree. With a
+// zero Interval expired entries are only removed by calling
+// Sweep (lazy-only). Otherwise a sweep runs on Insert once
+// Interval has passed since the previous one, removing at
+// most MaxWork expired entries (unlimited if zero). Iteration
+// and Len still see expired entries until they are removed.
+type CleanupPolicy struct {
+	Interval time.Duration
+	MaxWork  int
+}
+
+// WithClock sets the Clock used for TTL expiration
+func WithClock(c Clock) Option {
+	return func(t *Tree) {
+		t.clock = c
+	}
+}
+
+// WithCleanup sets the CleanupPolicy for expired entries
+func WithCleanup(p CleanupPolicy) Option {
+	return func(t *Tree) {
+		t.cleanup = p
+	}
+}
+
+// expiryEntry records when a key inserted with a TTL expires.
+// It is shared by the leaf and the heap, index being its
+// position in the heap so it can be removed from it directly.
+type expiryEntry struct {
+	key     string
+	expires time.Time
+	index   int
+}
+
+// expiryHeap is a min-heap of the expiry entries of all the
+// leafs with a TTL. Entries are removed as soon as their leaf
+// is updated or deleted, so it never holds stale entries.
+type expiryHeap []*expiryEntry
+
+func (h expiryHeap) Len() int {
+	return len(h)
+}
+
+func (h expiryHeap) Less(i, j int) bool {
+	return h[i].expires.Before(h[j].expires)
+}
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	e := x.(*expiryEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	e.index = -1
+	return e
+}
+
+// InsertWithTTL is like Insert, but the entry expires once
+// ttl has passed according to the tree Clock
+func (t *Tree) InsertWithTTL(s string, v interface{}, ttl time.Duration) (interface{}, bool) {
+	old, updated := t.Insert(s, v)
+
+	_, _, _, n := t.Find(t.root, s)
+	expires := t.clock.Now().Add(ttl)
+	t.setExpiry(s, n.leaf, expires)
+	t.logExpire(s, expires)
+
+	return old, updated
+}
+
+// Sweep removes expired entries, bounded by the MaxWork
+// of the CleanupPolicy. Returns how many were removed.
+func (t *Tree) Sweep() int {
+	now := t.clock.Now()
+	t.nextSweep = now.Add(t.cleanup.Interval)
+
+	removed := 0
+	for work := 0; len(t.expiry) > 0; work++ {
+		if t.cleanup.MaxWork > 0 && work >= t.cleanup.MaxWork {
+			break
+		}
+		e := t.expiry[0]
+		if e.expires.After(now) {
+			break
+		}
+
+		// Deleting the key removes its entry from the heap
+		if _, ok := t.deleteLeaf(e.key, nil); !ok {
+			heap.Remove(&t.expiry, e.index)
+			continue
+		}
+		t.logDelete(walDelete, e.key)
+		removed++
+	}
+	return removed
+}
+
+// maybeSweep runs a sweep if periodic cleanup is
+// enabled and the interval has passed
+func (t *Tree) maybeSweep() {
+	if t.cleanup.Interval <= 0 || len(t.expiry) == 0 {
+		return
+	}
+	if t.clock.Now().Before(t.nextSweep) {
+		return
+	}
+	t.Sweep()
+}
+
+// expired checks if a leaf inserted with a TTL has expired
+func (t *Tree) expired(l *LeafNode) bool {
+	if l.expiry == nil {
+		return false
+	}
+	return !t.clock.Now().Before(l.expiry.expires)
+}
+
+// setExpiry sets when the leaf stored under s expires
+func (t *Tree) setExpiry(s string, l *LeafNode, expires time.Time) {
+	if l.expiry != nil {
+		l.expiry.expires = expires
+		heap.Fix(&t.expiry, l.expiry.index)
+		return
+	}
+	l.expiry = &expiryEntry{key: s, expires: expires}
+	heap.Push(&t.expiry, l.expiry)
+}
+
+// clearExpiry removes the expiration of a leaf, if any
+func (t *Tree) clearExpiry(l *LeafNode) {
+	if l.expiry == nil {
+		return
+	}
+	heap.Remove(&t.expiry, l.expiry.index)
+	l.expiry = nil
+}
+
+// expiresAt returns when the leaf expires, zero if it has no TTL
+func (l *LeafNode) expiresAt() time.Time {
+	if l.expiry == nil {
+		return time.Time{}
+	}
+	return l.expiry.expires
+}
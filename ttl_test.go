@@ -0,0 +1,178 @@
+package radix
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced Clock
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestTTLLazy(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	r := NewWithOptions(WithClock(clock))
+
+	r.Insert("foo", 1)
+	r.InsertWithTTL("foobar", 2, time.Second)
+	r.InsertWithTTL("foobaz", 3, time.Minute)
+
+	if v, ok := r.Lookup("foobar"); !ok || v != 2 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+
+	clock.Advance(time.Second)
+	if _, ok := r.Lookup("foobar"); ok {
+		t.Fatalf("expected expired")
+	}
+	if _, ok := r.Get("foobar"); ok {
+		t.Fatalf("expected expired")
+	}
+	// Reads only hide expired entries
+	if r.Len() != 3 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+
+	m, v, ok := r.LongestPrefixOK("foobazzip")
+	if m != "foobaz" || v != 3 || !ok {
+		t.Fatalf("bad: %q %v %v", m, v, ok)
+	}
+	clock.Advance(time.Minute)
+	m, v, ok = r.LongestPrefixOK("foobazzip")
+	if m != "foo" || v != 1 || !ok {
+		t.Fatalf("bad: %q %v %v", m, v, ok)
+	}
+
+	// Without periodic cleanup entries stay until swept
+	r.Insert("zip", 4)
+	if r.Len() != 4 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+	if n := r.Sweep(); n != 2 {
+		t.Fatalf("bad sweep: %v", n)
+	}
+	if r.Len() != 2 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+}
+
+func TestTTLUpdateClearsExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	r := NewWithOptions(WithClock(clock), WithCleanup(CleanupPolicy{Interval: time.Second}))
+
+	r.InsertWithTTL("foo", 1, time.Second)
+	r.Insert("foo", 2)
+
+	clock.Advance(time.Hour)
+	if n := r.Sweep(); n != 0 {
+		t.Fatalf("bad sweep: %v", n)
+	}
+	if v, ok := r.Lookup("foo"); !ok || v != 2 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+}
+
+func TestTTLPeriodicSweep(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	r := NewWithOptions(
+		WithClock(clock),
+		WithCleanup(CleanupPolicy{Interval: time.Minute, MaxWork: 2}),
+	)
+
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		r.InsertWithTTL(k, k, time.Second)
+	}
+
+	clock.Advance(time.Minute)
+	r.Insert("z", 0)
+	if r.Len() != 4 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+
+	// Interval has not passed yet
+	r.Insert("y", 0)
+	if r.Len() != 5 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+
+	clock.Advance(time.Minute)
+	r.Insert("x", 0)
+	if r.Len() != 4 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+
+	if n := r.Sweep(); n != 1 {
+		t.Fatalf("bad sweep: %v", n)
+	}
+	if _, ok := r.Lookup("e"); ok {
+		t.Fatalf("expected expired")
+	}
+	if r.Len() != 3 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+}
+
+func TestTTLHeapBounded(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	r := NewWithOptions(WithClock(clock))
+
+	for i := 0; i < 1000; i++ {
+		r.InsertWithTTL("foo", i, time.Second)
+	}
+	r.InsertWithTTL("bar", 1, time.Second)
+	r.InsertWithTTL("foo/a", 1, time.Second)
+	r.InsertWithTTL("foo/b", 1, time.Second)
+	if len(r.expiry) != 4 {
+		t.Fatalf("bad heap size: %v", len(r.expiry))
+	}
+
+	r.Insert("foo", 0)
+	r.Delete("bar")
+	r.DeletePrefix("foo/")
+	if len(r.expiry) != 0 {
+		t.Fatalf("bad heap size: %v", len(r.expiry))
+	}
+
+	clock.Advance(time.Second)
+	if v, ok := r.Lookup("foo"); !ok || v != 0 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+}
+
+func TestTTLDeleteExpired(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	r := NewWithOptions(WithClock(clock))
+	r.InsertWithTTL("foo", 1, time.Second)
+	r.InsertWithTTL("bar", 2, time.Second)
+	r.InsertWithTTL("zip", 3, time.Second)
+	clock.Advance(time.Second)
+
+	// Expired entries are missing, like for Lookup
+	if v, ok := r.Delete("foo"); ok {
+		t.Fatalf("deleted expired key: %v", v)
+	}
+	if r.CompareAndDelete("bar", 2) {
+		t.Fatalf("deleted expired key")
+	}
+	called := false
+	if r.DeleteIf("zip", func(v interface{}) bool { called = true; return true }) || called {
+		t.Fatalf("deleted expired key")
+	}
+
+	// They are still removed by sweeps
+	if n := r.Sweep(); n != 3 {
+		t.Fatalf("bad sweep: %v", n)
+	}
+	if r.Len() != 0 {
+		t.Fatalf("bad len: %v", r.Len())
+	}
+}
@@ -0,0 +1,352 @@
+package radix
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"github.com/pkg/errors"
+	"io"
+	"math"
+	"time"
+)
+
+// walOp kind of write-ahead log record
+type walOp byte
+
+const (
+	walInvalid = walOp(0)
+	// Insert or update a key, followed by the encoded value
+	walInsert = walOp(1)
+	// Delete a key
+	walDelete = walOp(2)
+	// Delete all keys under a prefix
+	walDeletePrefix = walOp(3)
+	// Set the expiration of a key, followed by unix nanoseconds
+	walExpire = walOp(4)
+	// Length prefixed group of records applied all or nothing
+	walBatch = walOp(5)
+)
+
+// WALCodec is used to encode values in write-ahead log records
+type WALCodec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(b []byte) (interface{}, error)
+}
+
+// gobCodec is the default WALCodec. Each value is encoded as a
+// standalone gob stream so records can be replayed on their own.
+// Types other than the gob builtins must be registered with
+// gob.Register.
+type gobCodec struct{}
+
+func (gobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(b []byte) (interface{}, error) {
+	var v interface{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// WithWAL makes the tree append a record to w for every Insert,
+// Delete and DeletePrefix. Records are written with a single
+// Write call each. After the first write error logging stops and
+// the error is reported by WALErr.
+func WithWAL(w io.Writer) Option {
+	return func(t *Tree) {
+		t.wal = w
+	}
+}
+
+// WithWALCodec sets the codec used to encode values in
+// the write-ahead log and to decode them in ReplayWAL
+func WithWALCodec(c WALCodec) Option {
+	return func(t *Tree) {
+		t.walCodec = c
+	}
+}
+
+// WALErr returns the error which stopped write-ahead logging
+func (t *Tree) WALErr() error {
+	return t.walErr
+}
+
+// CompactWAL writes a checkpoint of the tree to w, made of one
+// record per entry, and makes w the write-ahead log for the
+// following changes. Replaying w alone restores the tree, so the
+// previous log can be discarded once this returns. If writing the
+// checkpoint fails, the previous log stays in use.
+func (t *Tree) CompactWAL(w io.Writer) error {
+	var rec []byte
+	err := t.VisitValues(t.root, func(key string, n *Node) error {
+		var err error
+		rec, err = t.appendInsertRecord(rec[:0], key, n.leaf.val)
+		if err != nil {
+			return err
+		}
+		if expires := n.leaf.expiresAt(); !expires.IsZero() {
+			rec = appendExpireRecord(rec, key, expires)
+		}
+		_, err = w.Write(rec)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "can't write checkpoint")
+	}
+
+	t.wal = w
+	t.walErr = nil
+	return nil
+}
+
+// ReplayWAL applies the records read from r onto a tree, using its
+// WALCodec to decode values. The records are not logged again.
+func ReplayWAL(r io.Reader, onto *Tree) error {
+	wal := onto.wal
+	onto.wal = nil
+	defer func() {
+		onto.wal = wal
+	}()
+
+	br := bufio.NewReader(r)
+	for i := 0; ; i++ {
+		err := onto.replayRecord(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "can't replay record %d", i)
+		}
+	}
+}
+
+// walRecord is a decoded write-ahead log record
+type walRecord struct {
+	op      walOp
+	key     string
+	val     interface{}
+	expires time.Time
+	batch   []walRecord
+}
+
+// replayRecord reads a single record and applies it. Returns
+// io.EOF if there are no more records. A batch record is fully
+// read and decoded before any of it is applied.
+func (t *Tree) replayRecord(r *bufio.Reader) error {
+	rec, err := t.readRecord(r)
+	if err != nil {
+		return err
+	}
+	return t.applyRecord(rec)
+}
+
+// readRecord reads and decodes a single record. Returns
+// io.EOF if there are no more records.
+func (t *Tree) readRecord(r *bufio.Reader) (walRecord, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return walRecord{}, err
+	}
+	rec := walRecord{op: walOp(b)}
+
+	if rec.op == walBatch {
+		body, err := readWALBytes(r)
+		if err != nil {
+			return walRecord{}, errors.Wrap(err, "can't read batch")
+		}
+		br := bufio.NewReader(bytes.NewReader(body))
+		for {
+			inner, err := t.readRecord(br)
+			if err == io.EOF {
+				return rec, nil
+			}
+			if err != nil {
+				return walRecord{}, errors.Wrap(err, "can't read batch record")
+			}
+			if inner.op == walBatch {
+				return walRecord{}, errors.New("nested batch")
+			}
+			rec.batch = append(rec.batch, inner)
+		}
+	}
+
+	key, err := readWALBytes(r)
+	if err != nil {
+		return walRecord{}, errors.Wrap(err, "can't read key")
+	}
+	rec.key = string(key)
+
+	switch rec.op {
+	case walInsert:
+		b, err := readWALBytes(r)
+		if err != nil {
+			return walRecord{}, errors.Wrap(err, "can't read value")
+		}
+		rec.val, err = t.walCodec.Decode(b)
+		if err != nil {
+			return walRecord{}, errors.Wrap(err, "can't decode value")
+		}
+	case walDelete, walDeletePrefix:
+	case walExpire:
+		nsec, err := binary.ReadVarint(r)
+		if err != nil {
+			return walRecord{}, errors.Wrap(noEOF(err), "can't read expiration")
+		}
+		rec.expires = time.Unix(0, nsec)
+	default:
+		return walRecord{}, errors.Errorf("invalid op %d", rec.op)
+	}
+	return rec, nil
+}
+
+// applyRecord applies a decoded record to the tree
+func (t *Tree) applyRecord(rec walRecord) error {
+	switch rec.op {
+	case walInsert:
+		t.Insert(rec.key, rec.val)
+	case walDelete:
+		// Sweeps log deletes of expired entries, which
+		// Delete would leave in place
+		t.deleteLeaf(rec.key, nil)
+	case walDeletePrefix:
+		t.DeletePrefix(rec.key)
+	case walExpire:
+		isFound, _, _, n := t.Find(t.root, rec.key)
+		if !isFound || !n.HasValue() {
+			return errors.Errorf("can't expire missing key %q", rec.key)
+		}
+		t.setExpiry(rec.key, n.leaf, rec.expires)
+	case walBatch:
+		for i, inner := range rec.batch {
+			if err := t.applyRecord(inner); err != nil {
+				return errors.Wrapf(err, "can't apply batch record %d", i)
+			}
+		}
+	}
+	return nil
+}
+
+// readWALBytes reads a length prefixed byte string. The length
+// comes from the log and may be corrupted, so the buffer only
+// grows with the bytes actually read instead of being allocated
+// upfront.
+func readWALBytes(r *bufio.Reader) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	if l > math.MaxInt64 {
+		return nil, errors.Errorf("invalid length %d", l)
+	}
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, int64(l)))
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	if uint64(n) < l {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
+}
+
+// noEOF turns EOF inside a record into ErrUnexpectedEOF
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// logInsert appends an insert record to the write-ahead log
+func (t *Tree) logInsert(s string, v interface{}) {
+	if t.wal == nil || t.walErr != nil {
+		return
+	}
+	rec, err := t.appendInsertRecord(nil, s, v)
+	if err != nil {
+		t.walErr = err
+		return
+	}
+	t.writeWAL(rec)
+}
+
+// logDelete appends a delete or delete prefix
+// record to the write-ahead log
+func (t *Tree) logDelete(op walOp, s string) {
+	if t.wal == nil || t.walErr != nil {
+		return
+	}
+	t.writeWAL(appendWALBytes([]byte{byte(op)}, s))
+}
+
+// logBatch appends the operations of a batch passed to Apply
+// to the write-ahead log as a single record, in one write
+func (t *Tree) logBatch(ops []Op) {
+	if t.wal == nil || t.walErr != nil {
+		return
+	}
+
+	var body []byte
+	for _, op := range ops {
+		if op.Kind != OpInsert {
+			body = appendWALBytes(append(body, byte(walDelete)), op.Key)
+			continue
+		}
+		var err error
+		body, err = t.appendInsertRecord(body, op.Key, op.Value)
+		if err != nil {
+			t.walErr = err
+			return
+		}
+	}
+	t.writeWAL(appendWALBytes([]byte{byte(walBatch)}, string(body)))
+}
+
+// logExpire appends an expiration record to the write-ahead log
+func (t *Tree) logExpire(s string, expires time.Time) {
+	if t.wal == nil || t.walErr != nil {
+		return
+	}
+	t.writeWAL(appendExpireRecord(nil, s, expires))
+}
+
+func (t *Tree) writeWAL(rec []byte) {
+	if _, err := t.wal.Write(rec); err != nil {
+		t.walErr = errors.Wrap(err, "can't write record")
+	}
+}
+
+// appendInsertRecord appends an insert record to dst
+func (t *Tree) appendInsertRecord(dst []byte, s string, v interface{}) ([]byte, error) {
+	b, err := t.walCodec.Encode(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't encode value of %q", s)
+	}
+	dst = appendWALBytes(append(dst, byte(walInsert)), s)
+	return appendWALBytes(dst, string(b)), nil
+}
+
+// appendExpireRecord appends an expiration record to dst
+func appendExpireRecord(dst []byte, s string, expires time.Time) []byte {
+	dst = appendWALBytes(append(dst, byte(walExpire)), s)
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], expires.UnixNano())
+	return append(dst, buf[:n]...)
+}
+
+// appendWALBytes appends a length prefixed byte string
+func appendWALBytes(dst []byte, s string) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(s)))
+	dst = append(dst, buf[:n]...)
+	return append(dst, s...)
+}
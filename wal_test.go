@@ -0,0 +1,211 @@
+package radix
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWAL(t *testing.T) {
+	var log bytes.Buffer
+	r := NewWithOptions(WithWAL(&log))
+
+	r.Insert("foo", 1)
+	r.Insert("foobar", "two")
+	r.Insert("foo/bar", 3.5)
+	r.Insert("foo/baz", true)
+	r.Insert("zip", 5)
+	r.Insert("foo", 10)
+	r.Delete("zip")
+	r.DeletePrefix("foo/")
+	r.CompareAndDelete("foobar", "other")
+	if err := r.WALErr(); err != nil {
+		t.Fatalf("can't write wal: %s", err)
+	}
+
+	out := New()
+	if err := ReplayWAL(bytes.NewReader(log.Bytes()), out); err != nil {
+		t.Fatalf("can't replay: %s", err)
+	}
+	if !reflect.DeepEqual(out.ToMap(), r.ToMap()) {
+		t.Fatalf("mis-match: %v %v", out.ToMap(), r.ToMap())
+	}
+	if out.Len() != r.Len() {
+		t.Fatalf("bad len: %v %v", out.Len(), r.Len())
+	}
+
+	// Truncated records are reported
+	if err := ReplayWAL(bytes.NewReader(log.Bytes()[:log.Len()-1]), New()); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestWALCompact(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(100, 0)}
+	var log bytes.Buffer
+	r := NewWithOptions(WithWAL(&log), WithClock(clock))
+	for i := 0; i < 100; i++ {
+		r.Insert(fmt.Sprintf("key%d", i), i)
+	}
+	r.DeletePrefix("key1")
+	r.InsertWithTTL("ttl", "v", time.Second)
+
+	var checkpoint bytes.Buffer
+	if err := r.CompactWAL(&checkpoint); err != nil {
+		t.Fatalf("can't compact: %s", err)
+	}
+	if checkpoint.Len() >= log.Len() {
+		t.Fatalf("checkpoint not compacted: %v %v", checkpoint.Len(), log.Len())
+	}
+
+	// Later changes are appended to the checkpoint
+	r.Insert("after", 1)
+	r.Delete("key2")
+
+	out := NewWithOptions(WithClock(clock))
+	if err := ReplayWAL(&checkpoint, out); err != nil {
+		t.Fatalf("can't replay: %s", err)
+	}
+	if !reflect.DeepEqual(out.ToMap(), r.ToMap()) {
+		t.Fatalf("mis-match: %v %v", out.ToMap(), r.ToMap())
+	}
+
+	clock.Advance(time.Second)
+	if _, ok := out.Lookup("ttl"); ok {
+		t.Fatalf("expiration not replayed")
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("disk full")
+}
+
+func TestWALWriteError(t *testing.T) {
+	r := NewWithOptions(WithWAL(failingWriter{}))
+	r.Insert("foo", 1)
+	if r.WALErr() == nil {
+		t.Fatalf("expected error")
+	}
+	if v, ok := r.Lookup("foo"); !ok || v != 1 {
+		t.Fatalf("bad: %v %v", v, ok)
+	}
+}
+
+// limitedWriter fails every write past the first n
+type limitedWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, fmt.Errorf("disk full")
+	}
+	w.n--
+	return w.Buffer.Write(p)
+}
+
+func TestWALCompactFailure(t *testing.T) {
+	var log bytes.Buffer
+	r := NewWithOptions(WithWAL(&log))
+	r.Insert("a", 1)
+	r.Insert("b", 2)
+
+	if err := r.CompactWAL(&limitedWriter{n: 1}); err == nil {
+		t.Fatalf("expected error")
+	}
+	if err := r.WALErr(); err != nil {
+		t.Fatalf("failed compaction stopped logging: %s", err)
+	}
+
+	// The previous log still receives changes
+	r.Insert("c", 3)
+	out := New()
+	if err := ReplayWAL(&log, out); err != nil {
+		t.Fatalf("can't replay: %s", err)
+	}
+	if !reflect.DeepEqual(out.ToMap(), r.ToMap()) {
+		t.Fatalf("mis-match: %v %v", out.ToMap(), r.ToMap())
+	}
+}
+
+func TestWALBatch(t *testing.T) {
+	var log bytes.Buffer
+	r := NewWithOptions(WithWAL(&log))
+	r.Insert("foo", 1)
+	r.Insert("bar", 2)
+	before := log.Len()
+
+	err := r.Apply([]Op{
+		{Kind: OpInsert, Key: "foo", Value: 10},
+		{Kind: OpDelete, Key: "bar"},
+		{Kind: OpInsert, Key: "zip", Value: 3},
+	})
+	if err != nil {
+		t.Fatalf("can't apply: %s", err)
+	}
+
+	out := New()
+	if err := ReplayWAL(bytes.NewReader(log.Bytes()), out); err != nil {
+		t.Fatalf("can't replay: %s", err)
+	}
+	if !reflect.DeepEqual(out.ToMap(), r.ToMap()) {
+		t.Fatalf("mis-match: %v %v", out.ToMap(), r.ToMap())
+	}
+
+	// A torn batch is not applied at all
+	for cut := before + 1; cut < log.Len(); cut++ {
+		out := New()
+		if err := ReplayWAL(bytes.NewReader(log.Bytes()[:cut]), out); err == nil {
+			t.Fatalf("expected error at %d", cut)
+		}
+		expected := map[string]interface{}{"foo": 1, "bar": 2}
+		if !reflect.DeepEqual(out.ToMap(), expected) {
+			t.Fatalf("half applied batch at %d: %v", cut, out.ToMap())
+		}
+	}
+}
+
+func TestWALCorruptedLength(t *testing.T) {
+	cases := [][]byte{
+		// Key length overflowing int64
+		{byte(walInsert), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+		// Key length past the end of the log
+		{byte(walDelete), 0x80, 0x80, 0x80, 0x80, 0x80, 0x20, 'a'},
+		// Value length past the end of the log
+		{byte(walInsert), 1, 'a', 0x80, 0x80, 0x80, 0x80, 0x80, 0x20},
+		// Varint longer than 64 bits
+		{byte(walBatch), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+	}
+
+	for _, log := range cases {
+		if err := ReplayWAL(bytes.NewReader(log), New()); err == nil {
+			t.Fatalf("expected error: %x", log)
+		}
+	}
+}
+
+func TestWALSweptDelete(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var log bytes.Buffer
+	r := NewWithOptions(WithWAL(&log), WithClock(clock))
+	r.InsertWithTTL("foo", 1, time.Second)
+	r.Insert("bar", 2)
+	clock.Advance(time.Second)
+	if n := r.Sweep(); n != 1 {
+		t.Fatalf("bad sweep: %v", n)
+	}
+
+	out := NewWithOptions(WithClock(clock))
+	if err := ReplayWAL(bytes.NewReader(log.Bytes()), out); err != nil {
+		t.Fatalf("can't replay: %s", err)
+	}
+	if out.Len() != r.Len() {
+		t.Fatalf("bad len: %v %v", out.Len(), r.Len())
+	}
+}